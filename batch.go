@@ -0,0 +1,132 @@
+// +build go1.7
+
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// Source is an image hashed by HashBatch.
+type Source struct {
+	// ID identifies the image in its Result.
+	ID string
+	// Image is hashed when it is not nil.
+	Image image.Image
+	// Reader is decoded as HashReader does when Image is nil. It is closed
+	// once the source is handled if it is an io.Closer, even when it is not
+	// read because Image is set or ctx is done.
+	Reader io.Reader
+	// Path is the image file decoded as HashFile does when Image and Reader are nil.
+	Path string
+}
+
+// Result is the hash of a Source, or the error which prevented it.
+type Result struct {
+	// ID is the ID of the Source.
+	ID   string
+	Hash Hash
+	Err  error
+}
+
+// HashBatch function decodes and hashes by hasher the images received from
+// sources on workers goroutines, runtime.GOMAXPROCS(0) if workers is zero or
+// less. Each source gives a Result with its ID, in no particular order, and
+// the errors of a source do not stop the others. The returned channel is
+// closed once sources is closed and every image is hashed, or once ctx is
+// done, in which case the remaining sources are not received and the results
+// not yet delivered are dropped. The pixel buffers of the perception hashes
+// are pooled, so that workers reuse them from one image to the next.
+// hasher is shared by the workers, so it should be safe for concurrent use,
+// as the built-in hashers are. A *ReusableHasher is not: each worker hashes
+// with its own copy of it instead.
+func HashBatch(ctx context.Context, sources <-chan Source, hasher Hasher, workers int, opts ...ReadOption) <-chan Result {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	o := newReadOptions(opts)
+	results := make(chan Result, workers)
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(hasher Hasher) {
+			defer wg.Done()
+			for {
+				var src Source
+				var ok bool
+				select {
+				case <-ctx.Done():
+					return
+				case src, ok = <-sources:
+					if !ok {
+						return
+					}
+				}
+				hash, err := o.hashSource(ctx, src, hasher)
+				select {
+				case <-ctx.Done():
+					return
+				case results <- Result{ID: src.ID, Hash: hash, Err: err}:
+				}
+			}
+		}(workerHasher(hasher))
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// workerHasher returns the hasher a worker of HashBatch hashes with.
+func workerHasher(hasher Hasher) Hasher {
+	if h, ok := hasher.(*ReusableHasher); ok {
+		return h.clone()
+	}
+	return hasher
+}
+
+// hashSource returns the hash of src by hasher unless ctx is done first.
+// The reader of src is closed in any case.
+func (o *readOptions) hashSource(ctx context.Context, src Source, hasher Hasher) (Hash, error) {
+	if c, ok := src.Reader.(io.Closer); ok {
+		defer c.Close()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	img := src.Image
+	if img == nil {
+		var err error
+		if img, err = o.open(src); err != nil {
+			return nil, err
+		}
+	}
+	return HashContext(ctx, hasher, img)
+}
+
+// open returns the image decoded from the reader or the file of src.
+func (o *readOptions) open(src Source) (image.Image, error) {
+	r := src.Reader
+	if r == nil {
+		if src.Path == "" {
+			return nil, errors.New("source " + src.ID + " has no image")
+		}
+		f, err := os.Open(src.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return o.read(r)
+}
@@ -0,0 +1,240 @@
+// +build go1.7
+
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHashBatch(t *testing.T) {
+	img := loadImage(t, "_examples/sample1.jpg")
+	data, err := ioutil.ReadFile("_examples/sample3.jpg")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	hasher := NewPerceptionHasher()
+	expected := map[string]string{}
+	for _, path := range []string{"_examples/sample1.jpg", "_examples/sample3.jpg", "_examples/sample4.jpg"} {
+		hash, err := HashFile(path, hasher)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		expected[path] = hash.ToString()
+	}
+
+	for _, workers := range []int{1, 3, 0} {
+		var sources []Source
+		for i := 0; i < 4; i++ {
+			sources = append(sources,
+				Source{ID: fmt.Sprintf("image-%d", i), Image: img},
+				Source{ID: fmt.Sprintf("reader-%d", i), Reader: bytes.NewReader(data)},
+				Source{ID: fmt.Sprintf("file-%d", i), Path: "_examples/sample4.jpg"},
+			)
+		}
+		sources = append(sources,
+			Source{ID: "missing", Path: "_examples/missing.jpg"},
+			Source{ID: "garbage", Reader: strings.NewReader("not an image")},
+			Source{ID: "empty"},
+		)
+
+		ch := make(chan Source)
+		go func() {
+			for _, src := range sources {
+				ch <- src
+			}
+			close(ch)
+		}()
+		seen := map[string]bool{}
+		for result := range HashBatch(context.Background(), ch, hasher, workers) {
+			if seen[result.ID] {
+				t.Errorf("%s is delivered twice", result.ID)
+			}
+			seen[result.ID] = true
+			var path string
+			switch {
+			case strings.HasPrefix(result.ID, "image-"):
+				path = "_examples/sample1.jpg"
+			case strings.HasPrefix(result.ID, "reader-"):
+				path = "_examples/sample3.jpg"
+			case strings.HasPrefix(result.ID, "file-"):
+				path = "_examples/sample4.jpg"
+			default:
+				if result.Err == nil || result.Hash != nil {
+					t.Errorf("%s is expected to fail but got %v", result.ID, result.Hash)
+				}
+				continue
+			}
+			if result.Err != nil {
+				t.Errorf("%s: %v", result.ID, result.Err)
+			} else if result.Hash.ToString() != expected[path] {
+				t.Errorf("%s is expected %v but got %v", result.ID, expected[path], result.Hash.ToString())
+			}
+		}
+		if len(seen) != len(sources) {
+			t.Errorf("HashBatch with %d workers is expected %d results but got %d", workers, len(sources), len(seen))
+		}
+	}
+}
+
+// concurrencyHasher records the maximum number of concurrent calls to Hash.
+type concurrencyHasher struct {
+	mu       sync.Mutex
+	running  int
+	maxCalls int
+}
+
+func (h *concurrencyHasher) Kind() Kind {
+	return AHash
+}
+
+func (h *concurrencyHasher) Hash(img image.Image) (Hash, error) {
+	h.mu.Lock()
+	h.running++
+	if h.running > h.maxCalls {
+		h.maxCalls = h.running
+	}
+	h.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	h.mu.Lock()
+	h.running--
+	h.mu.Unlock()
+	return NewImageHash(0, AHash), nil
+}
+
+func TestHashBatchWorkers(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	hasher := &concurrencyHasher{}
+	ch := make(chan Source, 40)
+	for i := 0; i < 40; i++ {
+		ch <- Source{ID: fmt.Sprint(i), Image: img}
+	}
+	close(ch)
+	n := 0
+	for range HashBatch(context.Background(), ch, hasher, 4) {
+		n++
+	}
+	if n != 40 {
+		t.Errorf("HashBatch is expected 40 results but got %d", n)
+	}
+	if hasher.maxCalls > 4 {
+		t.Errorf("HashBatch with 4 workers ran %d hashes concurrently", hasher.maxCalls)
+	}
+}
+
+func TestHashBatchCancel(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	ctx, cancel := context.WithCancel(context.Background())
+	// sources is never closed: the results are closed by the cancellation.
+	sources := make(chan Source)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case sources <- Source{ID: fmt.Sprint(i), Image: img}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	results := HashBatch(ctx, sources, NewAverageHasher(), 2)
+	for i := 0; i < 5; i++ {
+		if result := <-results; result.Err != nil {
+			t.Errorf("%s: %v", result.ID, result.Err)
+		}
+	}
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("HashBatch results are not closed after the cancellation")
+		}
+	}
+}
+
+// closeRecorder is a reader recording whether it is closed.
+type closeRecorder struct {
+	*strings.Reader
+	mu     sync.Mutex
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	return nil
+}
+
+func TestHashBatchClose(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	readers := []*closeRecorder{
+		{Reader: strings.NewReader("not an image")},
+		{Reader: strings.NewReader("not read")},
+	}
+	ch := make(chan Source, 2)
+	ch <- Source{ID: "garbage", Reader: readers[0]}
+	ch <- Source{ID: "image", Image: img, Reader: readers[1]}
+	close(ch)
+	for range HashBatch(context.Background(), ch, NewAverageHasher(), 2) {
+	}
+
+	// A source received once ctx is done is not read.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := &closeRecorder{Reader: strings.NewReader("not read")}
+	if _, err := newReadOptions(nil).hashSource(ctx, Source{ID: "canceled", Reader: canceled}, NewAverageHasher()); err != context.Canceled {
+		t.Errorf("hashSource with a canceled context is expected %v but got %v", context.Canceled, err)
+	}
+
+	for i, r := range append(readers, canceled) {
+		if !r.closed {
+			t.Errorf("Reader %d is expected to be closed", i)
+		}
+	}
+}
+
+func TestHashBatchReusableHasher(t *testing.T) {
+	hasher := NewReusablePerceptionHasher(8, 8)
+	var sources []Source
+	expected := map[string]string{}
+	for i, path := range []string{"_examples/sample1.jpg", "_examples/sample2.jpg", "_examples/sample3.jpg", "_examples/sample4.jpg"} {
+		img := loadImage(t, path)
+		hash, err := hasher.Hash(img)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		for j := 0; j < 4; j++ {
+			id := fmt.Sprintf("%d-%d", i, j)
+			sources = append(sources, Source{ID: id, Image: img})
+			expected[id] = hash.ToString()
+		}
+	}
+	ch := make(chan Source, len(sources))
+	for _, src := range sources {
+		ch <- src
+	}
+	close(ch)
+	for result := range HashBatch(context.Background(), ch, hasher, 4) {
+		if result.Err != nil {
+			t.Errorf("%s: %v", result.ID, result.Err)
+		} else if result.Hash.ToString() != expected[result.ID] {
+			t.Errorf("%s is expected %v but got %v", result.ID, expected[result.ID], result.Hash.ToString())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// +build go1.7
+
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"context"
+	"image"
+)
+
+// WithContext function returns an Option aborting the computation once ctx
+// is done. The hash functions then return ctx.Err().
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.done = ctx.Done()
+		o.err = ctx.Err
+	}
+}
+
+// contextOptions returns the settings of opts aborted by ctx.
+func contextOptions(ctx context.Context, opts []Option) *options {
+	o := newOptions(opts)
+	WithContext(ctx)(o)
+	return o
+}
+
+// AverageHashContext function returns AverageHash of img unless ctx is done first.
+// The computation is configured by opts, such as WithInterpolation.
+func AverageHashContext(ctx context.Context, img image.Image, opts ...Option) (*ImageHash, error) {
+	return averageHash(img, contextOptions(ctx, opts))
+}
+
+// DifferenceHashContext function returns DifferenceHash of img unless ctx is done first.
+// The computation is configured by opts, such as WithInterpolation.
+func DifferenceHashContext(ctx context.Context, img image.Image, opts ...Option) (*ImageHash, error) {
+	return differenceHash(img, contextOptions(ctx, opts))
+}
+
+// PerceptionHashContext function returns PerceptionHash of img unless ctx is done first.
+// The computation is configured by opts, such as WithInterpolation.
+func PerceptionHashContext(ctx context.Context, img image.Image, opts ...Option) (*ImageHash, error) {
+	return perceptionHash(img, contextOptions(ctx, opts))
+}
+
+// ExtAverageHashContext function returns ExtAverageHash of img unless ctx is done first.
+// The computation is configured by opts, such as WithInterpolation.
+func ExtAverageHashContext(ctx context.Context, img image.Image, width, height int, opts ...Option) (*ExtImageHash, error) {
+	return extAverageHash(img, width, height, contextOptions(ctx, opts))
+}
+
+// ExtDifferenceHashContext function returns ExtDifferenceHash of img unless ctx is done first.
+// The computation is configured by opts, such as WithInterpolation.
+func ExtDifferenceHashContext(ctx context.Context, img image.Image, width, height int, opts ...Option) (*ExtImageHash, error) {
+	return extDifferenceHash(img, width, height, contextOptions(ctx, opts))
+}
+
+// ExtPerceptionHashContext function returns ExtPerceptionHash of img unless ctx is done first.
+// The computation is configured by opts, such as WithInterpolation.
+// The DCT, which dominates large hashes, is aborted as soon as ctx is done.
+func ExtPerceptionHashContext(ctx context.Context, img image.Image, width, height int, opts ...Option) (*ExtImageHash, error) {
+	return extPerceptionHash(img, width, height, contextOptions(ctx, opts))
+}
+
+// HashContext function returns h.Hash(img) unless ctx is done first.
+// The built-in hashers are aborted while they compute, other hashers
+// are only checked before they start.
+func HashContext(ctx context.Context, h Hasher, img image.Image) (Hash, error) {
+	switch h := h.(type) {
+	case *imageHasher:
+		o := *h.opts
+		WithContext(ctx)(&o)
+		return (&imageHasher{kind: h.kind, opts: &o, fn: h.fn}).Hash(img)
+	case *extImageHasher:
+		o := *h.opts
+		WithContext(ctx)(&o)
+		return (&extImageHasher{kind: h.kind, width: h.width, height: h.height, opts: &o, fn: h.fn}).Hash(img)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return h.Hash(img)
+}
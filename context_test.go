@@ -0,0 +1,90 @@
+// +build go1.7
+
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+)
+
+func TestHashContext(t *testing.T) {
+	img := loadImage(t, "_examples/sample1.jpg")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tt := range []struct {
+		name     string
+		expected func(img image.Image) (Hash, error)
+		actual   func(ctx context.Context, img image.Image) (Hash, error)
+	}{
+		{"AverageHash",
+			func(img image.Image) (Hash, error) { return AverageHash(img) },
+			func(ctx context.Context, img image.Image) (Hash, error) { return AverageHashContext(ctx, img) }},
+		{"DifferenceHash",
+			func(img image.Image) (Hash, error) { return DifferenceHash(img) },
+			func(ctx context.Context, img image.Image) (Hash, error) { return DifferenceHashContext(ctx, img) }},
+		{"PerceptionHash",
+			func(img image.Image) (Hash, error) { return PerceptionHash(img) },
+			func(ctx context.Context, img image.Image) (Hash, error) { return PerceptionHashContext(ctx, img) }},
+		{"ExtAverageHash",
+			func(img image.Image) (Hash, error) { return ExtAverageHash(img, 16, 16) },
+			func(ctx context.Context, img image.Image) (Hash, error) { return ExtAverageHashContext(ctx, img, 16, 16) }},
+		{"ExtDifferenceHash",
+			func(img image.Image) (Hash, error) { return ExtDifferenceHash(img, 16, 16) },
+			func(ctx context.Context, img image.Image) (Hash, error) { return ExtDifferenceHashContext(ctx, img, 16, 16) }},
+		{"ExtPerceptionHash",
+			func(img image.Image) (Hash, error) { return ExtPerceptionHash(img, 8, 8) },
+			func(ctx context.Context, img image.Image) (Hash, error) { return ExtPerceptionHashContext(ctx, img, 8, 8) }},
+		{"AverageHash with options",
+			func(img image.Image) (Hash, error) { return NewAverageHasher(WithInterpolation(Lanczos3)).Hash(img) },
+			func(ctx context.Context, img image.Image) (Hash, error) {
+				return AverageHashContext(ctx, img, WithInterpolation(Lanczos3))
+			}},
+		{"ExtPerceptionHash with options",
+			func(img image.Image) (Hash, error) { return NewExtPerceptionHasher(16, 16, WithLuma(Rec709)).Hash(img) },
+			func(ctx context.Context, img image.Image) (Hash, error) {
+				return ExtPerceptionHashContext(ctx, img, 16, 16, WithLuma(Rec709))
+			}},
+		{"HashContext",
+			func(img image.Image) (Hash, error) { return NewExtPerceptionHasher(8, 8).Hash(img) },
+			func(ctx context.Context, img image.Image) (Hash, error) {
+				return HashContext(ctx, NewExtPerceptionHasher(8, 8), img)
+			}},
+	} {
+		expected, err := tt.expected(img)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		actual, err := tt.actual(context.Background(), img)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		} else if actual.ToString() != expected.ToString() {
+			t.Errorf("%s is expected %s but got %s", tt.name, expected.ToString(), actual.ToString())
+		}
+
+		if _, err := tt.actual(canceled, img); err != context.Canceled {
+			t.Errorf("%s with a canceled context is expected %v but got %v", tt.name, context.Canceled, err)
+		}
+	}
+}
+
+func TestExtPerceptionHashContextDeadline(t *testing.T) {
+	img := loadImage(t, "_examples/sample1.jpg")
+
+	// A 32x32 phash runs a 1024x1024 DCT which takes much longer than the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ExtPerceptionHashContext(ctx, img, 32, 32)
+	if err != context.DeadlineExceeded {
+		t.Errorf("ExtPerceptionHashContext is expected %v but got %v", context.DeadlineExceeded, err)
+	}
+	t.Logf("aborted after %v", time.Since(start))
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+// Distances function writes into out the distance between query and each
+// 64bits hash of corpus, as returned by ImageHash.GetHash. out should hold
+// at least len(corpus) values. Unlike ImageHash.Distance, kinds are not
+// checked, which makes linear scans of large corpora several times faster.
+func Distances(query uint64, corpus []uint64, out []int) {
+	out = out[:len(corpus)]
+	i := 0
+	for ; i+4 <= len(corpus); i += 4 {
+		c := corpus[i : i+4 : i+4]
+		o := out[i : i+4 : i+4]
+		o[0] = popcnt(query ^ c[0])
+		o[1] = popcnt(query ^ c[1])
+		o[2] = popcnt(query ^ c[2])
+		o[3] = popcnt(query ^ c[3])
+	}
+	for ; i < len(corpus); i++ {
+		out[i] = popcnt(query ^ corpus[i])
+	}
+}
+
+// WithinDistance function returns the indices, in increasing order, of the
+// 64bits hashes of corpus at most maxDistance away from query.
+func WithinDistance(query uint64, corpus []uint64, maxDistance int) []int {
+	var indices []int
+	i := 0
+	for ; i+4 <= len(corpus); i += 4 {
+		c := corpus[i : i+4 : i+4]
+		d0 := maxDistance - popcnt(query^c[0])
+		d1 := maxDistance - popcnt(query^c[1])
+		d2 := maxDistance - popcnt(query^c[2])
+		d3 := maxDistance - popcnt(query^c[3])
+		// Most hashes are far away: skip them with a single branch.
+		if d0&d1&d2&d3 < 0 {
+			continue
+		}
+		if d0 >= 0 {
+			indices = append(indices, i)
+		}
+		if d1 >= 0 {
+			indices = append(indices, i+1)
+		}
+		if d2 >= 0 {
+			indices = append(indices, i+2)
+		}
+		if d3 >= 0 {
+			indices = append(indices, i+3)
+		}
+	}
+	for ; i < len(corpus); i++ {
+		if popcnt(query^corpus[i]) <= maxDistance {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// checkCorpus panics unless corpus holds whole hashes of len(query) words.
+func checkCorpus(query, corpus []uint64) {
+	if len(query) == 0 || len(corpus)%len(query) != 0 {
+		panic("incorrect corpus size, wanted a multiple of the query size.")
+	}
+}
+
+// ExtDistances function writes into out the distance between query and each
+// hash of corpus, where corpus stores one hash every len(query) words as
+// returned by ExtImageHash.GetHash. out should hold at least
+// len(corpus)/len(query) values. Kinds and sizes are not checked.
+func ExtDistances(query []uint64, corpus []uint64, out []int) {
+	checkCorpus(query, corpus)
+	words := len(query)
+	out = out[:len(corpus)/words]
+	if words == 4 {
+		// 256bits hashes, the usual extended size, are unrolled.
+		q := query[:4:4]
+		for i := range out {
+			c := corpus[i*4 : i*4+4 : i*4+4]
+			out[i] = popcnt(q[0]^c[0]) + popcnt(q[1]^c[1]) + popcnt(q[2]^c[2]) + popcnt(q[3]^c[3])
+		}
+		return
+	}
+	for i := range out {
+		c := corpus[i*words : (i+1)*words]
+		distance := 0
+		for j, q := range query {
+			distance += popcnt(q ^ c[j])
+		}
+		out[i] = distance
+	}
+}
+
+// ExtWithinDistance function returns the indices, in increasing order, of the
+// hashes of corpus at most maxDistance away from query, where corpus stores
+// one hash every len(query) words. The comparison of a hash stops as soon as
+// it exceeds maxDistance.
+func ExtWithinDistance(query []uint64, corpus []uint64, maxDistance int) []int {
+	checkCorpus(query, corpus)
+	words := len(query)
+	var indices []int
+	for i, n := 0, len(corpus)/words; i < n; i++ {
+		c := corpus[i*words : (i+1)*words]
+		distance := 0
+		for j, q := range query {
+			if distance += popcnt(q ^ c[j]); distance > maxDistance {
+				break
+			}
+		}
+		if distance <= maxDistance {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
@@ -0,0 +1,11 @@
+// +build go1.20
+
+package goimagehash
+
+import "testing"
+
+// reportHashRate reports the number of hashes compared per second,
+// n hashes being compared by each iteration.
+func reportHashRate(b *testing.B, n int) {
+	b.ReportMetric(float64(n)*float64(b.N)/b.Elapsed().Seconds(), "hashes/s")
+}
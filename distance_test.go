@@ -0,0 +1,139 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// randomHashes returns n reproducible random words.
+func randomHashes(n int) []uint64 {
+	r := rand.New(rand.NewSource(int64(n)))
+	hashes := make([]uint64, n)
+	for i := range hashes {
+		hashes[i] = uint64(r.Int63())<<1 ^ uint64(r.Int63())
+	}
+	return hashes
+}
+
+func TestDistances(t *testing.T) {
+	for _, n := range []int{0, 1, 3, 4, 7, 64, 1001} {
+		corpus := randomHashes(n)
+		query := NewImageHash(0xf0f0f0f00f0f0f0f, AHash)
+		out := make([]int, n+2)
+		Distances(query.GetHash(), corpus, out)
+		var expected []int
+		for i, h := range corpus {
+			d, err := query.Distance(NewImageHash(h, AHash))
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if out[i] != d {
+				t.Errorf("Distances of hash %d of %d is expected %d but got %d", i, n, d, out[i])
+			}
+			if d <= 28 {
+				expected = append(expected, i)
+			}
+		}
+		if within := WithinDistance(query.GetHash(), corpus, 28); !reflect.DeepEqual(within, expected) {
+			t.Errorf("WithinDistance of %d hashes is expected %v but got %v", n, expected, within)
+		}
+	}
+}
+
+func TestExtDistances(t *testing.T) {
+	for _, words := range []int{1, 2, 4, 5, 16} {
+		for _, n := range []int{0, 1, 9} {
+			corpus := randomHashes(words * n)
+			query := NewExtImageHash(randomHashes(words + 1)[1:], PHash, words*64)
+			out := make([]int, n)
+			ExtDistances(query.GetHash(), corpus, out)
+			var expected []int
+			maxDistance := words * 30
+			for i := 0; i < n; i++ {
+				d, err := query.Distance(NewExtImageHash(corpus[i*words:(i+1)*words], PHash, words*64))
+				if err != nil {
+					t.Fatalf("%s", err)
+				}
+				if out[i] != d {
+					t.Errorf("ExtDistances of hash %d of %d words is expected %d but got %d", i, words, d, out[i])
+				}
+				if d <= maxDistance {
+					expected = append(expected, i)
+				}
+			}
+			if within := ExtWithinDistance(query.GetHash(), corpus, maxDistance); !reflect.DeepEqual(within, expected) {
+				t.Errorf("ExtWithinDistance of %d hashes of %d words is expected %v but got %v", n, words, expected, within)
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("ExtDistances of a corpus of partial hashes is expected to panic")
+		}
+	}()
+	ExtDistances(make([]uint64, 4), make([]uint64, 6), make([]int, 2))
+}
+
+const benchmarkCorpusSize = 1 << 16
+
+func BenchmarkDistanceMethod(b *testing.B) {
+	corpus := make([]*ImageHash, benchmarkCorpusSize)
+	for i, h := range randomHashes(benchmarkCorpusSize) {
+		corpus[i] = NewImageHash(h, PHash)
+	}
+	query := NewImageHash(0x0123456789abcdef, PHash)
+	out := make([]int, len(corpus))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, h := range corpus {
+			out[j], _ = query.Distance(h)
+		}
+	}
+	reportHashRate(b, len(corpus))
+}
+
+func BenchmarkDistances(b *testing.B) {
+	corpus := randomHashes(benchmarkCorpusSize)
+	out := make([]int, len(corpus))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Distances(0x0123456789abcdef, corpus, out)
+	}
+	reportHashRate(b, len(corpus))
+}
+
+func BenchmarkWithinDistance(b *testing.B) {
+	corpus := randomHashes(benchmarkCorpusSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		WithinDistance(0x0123456789abcdef, corpus, 10)
+	}
+	reportHashRate(b, len(corpus))
+}
+
+func BenchmarkExtDistances256(b *testing.B) {
+	corpus := randomHashes(4 * benchmarkCorpusSize)
+	query := randomHashes(5)[1:]
+	out := make([]int, benchmarkCorpusSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ExtDistances(query, corpus, out)
+	}
+	reportHashRate(b, benchmarkCorpusSize)
+}
+
+func BenchmarkExtWithinDistance256(b *testing.B) {
+	corpus := randomHashes(4 * benchmarkCorpusSize)
+	query := randomHashes(5)[1:]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ExtWithinDistance(query, corpus, 40)
+	}
+	reportHashRate(b, benchmarkCorpusSize)
+}
@@ -30,6 +30,13 @@ func MedianOfPixels(pixels []float64) float64 {
 	return v
 }
 
+// MedianOfPixelsInPlace function returns the same median value of pixels as
+// MedianOfPixels without copying them, so pixels are reordered.
+func MedianOfPixelsInPlace(pixels []float64) float64 {
+	l := len(pixels)
+	return quickSelectMedian(pixels, 0, l-1, l/2)
+}
+
 // MedianOfPixelsFast64 function returns a median value of pixels.
 // It uses quick selection algorithm.
 func MedianOfPixelsFast64(pixels []float64) float64 {
@@ -39,5 +39,9 @@ func TestMedianPixels(t *testing.T) {
 		if result != tt.expected {
 			t.Errorf("Median of %v is expected as %v but got %v.", pixels, tt.expected, result)
 		}
+		result = MedianOfPixelsInPlace(append([]float64{}, pixels...))
+		if result != tt.expected {
+			t.Errorf("Median in place of %v is expected as %v but got %v.", pixels, tt.expected, result)
+		}
 	}
 }
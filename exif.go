@@ -0,0 +1,172 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// TIFF tags read from EXIF data.
+const (
+	exifOrientationTag     = 0x0112
+	exifThumbnailOffsetTag = 0x0201
+	exifThumbnailLengthTag = 0x0202
+)
+
+// exifData returns the TIFF structure holding the EXIF data of an encoded
+// JPEG or TIFF image, or nil when it has none.
+func exifData(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8")):
+		return jpegExif(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return data
+	}
+	return nil
+}
+
+// exifOrientation returns the EXIF orientation of an encoded JPEG or TIFF
+// image, or 1 when it has none.
+func exifOrientation(data []byte) int {
+	t, ok := newTIFF(exifData(data))
+	if !ok {
+		return 1
+	}
+	entries, _ := t.ifd(t.first)
+	for _, e := range entries {
+		if e.tag != exifOrientationTag {
+			continue
+		}
+		// The orientation is a single SHORT stored in the value field.
+		if e.typ != 3 || e.count != 1 {
+			return 1
+		}
+		if v := int(t.order.Uint16(e.value)); v >= 1 && v <= 8 {
+			return v
+		}
+		return 1
+	}
+	return 1
+}
+
+// exifThumbnail returns the JPEG thumbnail embedded in the second IFD of the
+// EXIF data of an encoded JPEG or TIFF image, or nil when it has none.
+func exifThumbnail(data []byte) []byte {
+	t, ok := newTIFF(exifData(data))
+	if !ok {
+		return nil
+	}
+	_, next := t.ifd(t.first)
+	entries, _ := t.ifd(next)
+	offset, length := -1, -1
+	for _, e := range entries {
+		if e.count != 1 || (e.typ != 3 && e.typ != 4) {
+			continue
+		}
+		v := int(t.order.Uint16(e.value))
+		if e.typ == 4 {
+			v = int(t.order.Uint32(e.value))
+		}
+		switch e.tag {
+		case exifThumbnailOffsetTag:
+			offset = v
+		case exifThumbnailLengthTag:
+			length = v
+		}
+	}
+	if offset < 8 || length <= 0 || offset+length > len(t.data) || offset+length < offset {
+		return nil
+	}
+	return t.data[offset : offset+length]
+}
+
+// jpegExif returns the TIFF structure of the APP1 Exif segment of a JPEG image.
+func jpegExif(data []byte) []byte {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return nil
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xff: // fill byte
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7): // no payload
+			i += 2
+			continue
+		case marker == 0xda || marker == 0xd9: // start of scan or end of image
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return nil
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		i += 2 + size
+	}
+	return nil
+}
+
+// tiff is a TIFF structure, as found in EXIF data.
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+	first int
+}
+
+// tiffEntry is an entry of an IFD. value holds the 4 bytes of the value field.
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte
+}
+
+func newTIFF(data []byte) (*tiff, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+	t := &tiff{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, false
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, false
+	}
+	t.first = int(t.order.Uint32(data[4:]))
+	return t, true
+}
+
+// ifd returns the entries of the IFD at offset and the offset of the next
+// IFD. Invalid IFDs have no entries and no next IFD.
+func (t *tiff) ifd(offset int) (entries []tiffEntry, next int) {
+	if offset < 8 || offset+2 > len(t.data) {
+		return nil, 0
+	}
+	n := int(t.order.Uint16(t.data[offset:]))
+	end := offset + 2 + n*12
+	if end+4 > len(t.data) {
+		return nil, 0
+	}
+	entries = make([]tiffEntry, n)
+	for i := range entries {
+		e := t.data[offset+2+i*12:]
+		entries[i] = tiffEntry{
+			tag:   t.order.Uint16(e),
+			typ:   t.order.Uint16(e[2:]),
+			count: t.order.Uint32(e[4:]),
+			value: e[8:12],
+		}
+	}
+	return entries, int(t.order.Uint32(t.data[end:]))
+}
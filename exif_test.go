@@ -0,0 +1,104 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// exifTIFF returns a TIFF structure holding an orientation and, unless it is
+// nil, a thumbnail in order.
+func exifTIFF(order binary.ByteOrder, orientation int, thumbnail []byte) []byte {
+	var b bytes.Buffer
+	if order == binary.ByteOrder(binary.LittleEndian) {
+		b.WriteString("II")
+	} else {
+		b.WriteString("MM")
+	}
+	entry := func(tag, typ uint16, value uint32) {
+		binary.Write(&b, order, tag)
+		binary.Write(&b, order, typ)
+		binary.Write(&b, order, uint32(1))
+		if typ == 3 {
+			binary.Write(&b, order, uint16(value))
+			binary.Write(&b, order, uint16(0))
+		} else {
+			binary.Write(&b, order, value)
+		}
+	}
+	binary.Write(&b, order, uint16(42))
+	binary.Write(&b, order, uint32(8))
+	binary.Write(&b, order, uint16(1))
+	entry(exifOrientationTag, 3, uint32(orientation))
+	if thumbnail == nil {
+		binary.Write(&b, order, uint32(0))
+		return b.Bytes()
+	}
+	binary.Write(&b, order, uint32(b.Len()+4))
+	binary.Write(&b, order, uint16(2))
+	entry(exifThumbnailOffsetTag, 4, uint32(b.Len()+2*12+4))
+	entry(exifThumbnailLengthTag, 4, uint32(len(thumbnail)))
+	binary.Write(&b, order, uint32(0))
+	b.Write(thumbnail)
+	return b.Bytes()
+}
+
+// withExif returns a JPEG image with an APP1 Exif segment holding tiff.
+func withExif(jpeg, tiff []byte) []byte {
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	out := append([]byte{}, jpeg[:2]...)
+	out = append(out, 0xff, 0xe1, byte((len(segment)+2)>>8), byte(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, jpeg[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	// A minimal JPEG header with an APP0 segment before the image data.
+	jpeg := []byte("\xff\xd8\xff\xe0\x00\x04ab\xff\xdb\x00\x02\xff\xda")
+
+	for _, tt := range []struct {
+		name     string
+		data     []byte
+		expected int
+	}{
+		{"jpeg without exif", jpeg, 1},
+		{"jpeg little endian", withExif(jpeg, exifTIFF(binary.LittleEndian, 6, nil)), 6},
+		{"jpeg big endian", withExif(jpeg, exifTIFF(binary.BigEndian, 8, nil)), 8},
+		{"jpeg invalid orientation", withExif(jpeg, exifTIFF(binary.BigEndian, 9, nil)), 1},
+		{"jpeg truncated exif", withExif(jpeg, exifTIFF(binary.BigEndian, 3, nil)[:12]), 1},
+		{"tiff", exifTIFF(binary.LittleEndian, 3, nil), 3},
+		{"jpeg with thumbnail", withExif(jpeg, exifTIFF(binary.LittleEndian, 5, []byte("thumbnail"))), 5},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"empty", nil, 1},
+	} {
+		if o := exifOrientation(tt.data); o != tt.expected {
+			t.Errorf("exifOrientation of %s is expected %d but got %d", tt.name, tt.expected, o)
+		}
+	}
+}
+
+func TestExifThumbnail(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xda")
+	thumbnail := []byte("\xff\xd8thumbnail")
+
+	for _, tt := range []struct {
+		name     string
+		data     []byte
+		expected []byte
+	}{
+		{"little endian", withExif(jpeg, exifTIFF(binary.LittleEndian, 1, thumbnail)), thumbnail},
+		{"big endian", withExif(jpeg, exifTIFF(binary.BigEndian, 6, thumbnail)), thumbnail},
+		{"tiff", exifTIFF(binary.BigEndian, 1, thumbnail), thumbnail},
+		{"no thumbnail", withExif(jpeg, exifTIFF(binary.BigEndian, 1, nil)), nil},
+		{"truncated", withExif(jpeg, exifTIFF(binary.BigEndian, 1, thumbnail)[:40]), nil},
+		{"no exif", jpeg, nil},
+	} {
+		if thumb := exifThumbnail(tt.data); !bytes.Equal(thumb, tt.expected) {
+			t.Errorf("exifThumbnail of %s is expected %q but got %q", tt.name, tt.expected, thumb)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"errors"
+	"image"
+	"sort"
+
+	"github.com/corona10/goimagehash/transforms"
+)
+
+// WithFixedPoint function returns an Option computing the average, difference
+// and perception hashes with integer arithmetic only, so that an image gets
+// the same hash on every architecture. Floating-point pipelines may flip
+// borderline bits between architectures which fuse or round operations
+// differently. Colors are converted with the Rec. 601 luma and images are
+// downscaled by area averaging, so the interpolation and the luma are
+// ignored, and the hashes differ from the default ones. Wavelet hashes and
+// WithPythonCompat are not affected.
+func WithFixedPoint() Option {
+	return func(o *options) {
+		o.fixedPoint = true
+	}
+}
+
+// fixedGrayscale returns img resized to width x height 16 bits gray levels.
+func fixedGrayscale(img image.Image, width, height int, o *options) ([]uint16, error) {
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("width and height should be positive")
+	}
+	pixels := make([]uint16, width*height)
+	if err := transforms.ResampleFixed(img, pixels, width, height, o.done); err != nil {
+		return nil, o.failure(err)
+	}
+	return pixels, nil
+}
+
+// packBits returns n bits packed 64 by 64 from the most significant bit.
+func packBits(n int, bit func(i int) bool) []uint64 {
+	bits := make([]uint64, (n+63)/64)
+	for i := 0; i < n; i++ {
+		if bit(i) {
+			bits[i/64] |= 1 << uint(63-i%64)
+		}
+	}
+	return bits
+}
+
+// fixedAverageBits returns the bits of the width x height gray levels
+// brighter than their mean, without dividing their sum.
+func fixedAverageBits(img image.Image, width, height int, o *options) ([]uint64, error) {
+	pixels, err := fixedGrayscale(img, width, height, o)
+	if err != nil {
+		return nil, err
+	}
+	sum := uint64(0)
+	for _, p := range pixels {
+		sum += uint64(p)
+	}
+	n := uint64(len(pixels))
+	return packBits(len(pixels), func(i int) bool {
+		return uint64(pixels[i])*n > sum
+	}), nil
+}
+
+// fixedDifferenceBits returns the bits of the gray levels darker than their
+// right neighbour in a (width + 1) x height array.
+func fixedDifferenceBits(img image.Image, width, height int, o *options) ([]uint64, error) {
+	if width <= 0 {
+		return nil, errors.New("width and height should be positive")
+	}
+	pixels, err := fixedGrayscale(img, width+1, height, o)
+	if err != nil {
+		return nil, err
+	}
+	return packBits(width*height, func(i int) bool {
+		p := i/width*(width+1) + i%width
+		return pixels[p] < pixels[p+1]
+	}), nil
+}
+
+// int64s sorts coefficients.
+type int64s []int64
+
+func (s int64s) Len() int           { return len(s) }
+func (s int64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// fixedPerceptionBits returns the bits of the width x height lowest
+// frequencies of the DCT of the gray levels resized to a square of
+// width * height pixels which are above their median.
+func fixedPerceptionBits(img image.Image, width, height int, o *options) ([]uint64, error) {
+	imgSize := width * height
+	if imgSize <= 0 || imgSize&(imgSize-1) != 0 {
+		return nil, errors.New("width * height should be power of 2")
+	}
+	pixels, err := fixedGrayscale(img, imgSize, imgSize, o)
+	if err != nil {
+		return nil, err
+	}
+	coeffs := make([]int64, width*height)
+	if err := transforms.DCT2DFixed(pixels, imgSize, width, height, coeffs); err != nil {
+		return nil, err
+	}
+	sorted := append(int64s{}, coeffs...)
+	sort.Sort(sorted)
+	// Twice the median keeps the mean of the middle values integral.
+	k := len(sorted) / 2
+	median2 := 2 * sorted[k]
+	if len(sorted)%2 == 0 {
+		median2 = sorted[k-1] + sorted[k]
+	}
+	return packBits(len(coeffs), func(i int) bool {
+		return 2*coeffs[i] > median2
+	}), nil
+}
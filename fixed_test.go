@@ -0,0 +1,124 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"image"
+	"testing"
+)
+
+// fixedImage returns a reproducible RGBA image computed with integers only.
+func fixedImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	seed := uint32(width*height + 7)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			seed = seed*1103515245 + 12345
+			noise := uint8(seed >> 27)
+			i := img.PixOffset(x, y)
+			img.Pix[i+0] = uint8(x*255/width) + noise
+			img.Pix[i+1] = uint8(y*255/height) + noise
+			img.Pix[i+2] = uint8((x+y)*127/(width+height)) + noise
+			img.Pix[i+3] = 0xff
+		}
+	}
+	return img
+}
+
+// TestFixedPointGolden checks hashes computed on amd64, so that every
+// architecture computes the same ones.
+func TestFixedPointGolden(t *testing.T) {
+	synthetic := fixedImage(301, 199)
+	for _, tt := range []struct {
+		path     string
+		name     string
+		expected string
+	}{
+		{"", "ahash", "a:0000020f3fffff1f"},
+		{"", "dhash", "d:fefefefefefefefe"},
+		{"", "phash", "p:955f20df20ad02fd"},
+		{"", "dhash-256", "d:fffcfffcfffcfffcfffcfffcfffcfffcfffcfffcfffcfffcfffcfffcebdceeec"},
+		{"", "phash-256", "p:95547f9f2060ff9f2070ad9f022afd9b2261bdd744229997046af9d926ab540e"},
+		{"_examples/sample1.jpg", "ahash", "a:7cff3f010703c1fc"},
+		{"_examples/sample1.jpg", "dhash", "d:e80f6d63af4f0b89"},
+		{"_examples/sample1.jpg", "phash", "p:af97d2205c6b1f82"},
+		{"_examples/sample1.jpg", "ahash-16x8", "a:9ff8ffff8fff8007001f800ff807ffe0"},
+		{"_examples/sample1.jpg", "dhash-256", "d:f04134d0e0bf0bb7757335fabe1c6af64dfe3e762cde24ae4167c4e750c5b442"},
+		{"_examples/sample1.jpg", "phash-256", "p:aff295bcd20e2037443c6b401fa182fe70fd4f7a7c00f80ba3b743ec0e60783f"},
+		{"_examples/sample3.jpg", "ahash", "a:7cff3f010703c1fc"},
+		{"_examples/sample3.jpg", "dhash", "d:e08f6d63af4f0b89"},
+		{"_examples/sample3.jpg", "phash", "p:af95d2205c7b1f82"},
+		{"_examples/sample3.jpg", "phash-256", "p:aff295bcd20b20375c1c6b401fa182fe70fd4f72fe00f80ba3b643e88e40783f"},
+		{"_examples/sample4.jpg", "ahash", "a:183c3e3e7e3e3c08"},
+		{"_examples/sample4.jpg", "dhash", "d:707cd4e8d8cc7030"},
+		{"_examples/sample4.jpg", "phash", "p:979c5a9369cd6518"},
+		{"_examples/sample4.jpg", "ahash-16x8", "a:03e00ff81ff81ffc1ffc1ef80ff001c0"},
+		{"_examples/sample4.jpg", "dhash-256", "d:07000e401da039b03b3039186e586cd06fc065e830f831b039b01da00f800280"},
+		{"_examples/sample4.jpg", "phash-256", "p:97a19cb84a5f93c56968cd6e65a518e9379661a7669e3187661a639a666926d8"},
+	} {
+		hasher, err := LookupHasher(tt.name, WithFixedPoint())
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		var hash Hash
+		if tt.path == "" {
+			hash, err = hasher.Hash(synthetic)
+		} else {
+			// The JPEG decoder of the standard library uses integers only.
+			hash, err = HashFile(tt.path, hasher)
+		}
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		if hash.ToString() != tt.expected {
+			t.Errorf("%s of %q with fixed point is expected %v but got %v", tt.name, tt.path, tt.expected, hash.ToString())
+		}
+	}
+}
+
+func TestWithFixedPoint(t *testing.T) {
+	img := loadImage(t, "_examples/sample1.jpg")
+	// The fixed-point hashes follow the floating-point ones with the same
+	// luma and interpolation but for borderline bits.
+	for _, name := range []string{"ahash", "dhash", "phash", "ahash-256", "dhash-256", "phash-256"} {
+		fixed, err := LookupHasher(name, WithFixedPoint())
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		float, err := LookupHasher(name, WithInterpolation(AreaAverage), WithLuma(Rec601))
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		h1, err := fixed.Hash(img)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		h2, err := float.Hash(img)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if d := hashDistance(t, h1, h2); d > h1.Bits()/16 {
+			t.Errorf("%s with fixed point is %d bits away from floating point", name, d)
+		}
+	}
+
+	for _, tt := range []struct {
+		width, height int
+	}{
+		{0, 8},
+		{8, 0},
+		{6, 6},
+	} {
+		if _, err := NewExtPerceptionHasher(tt.width, tt.height, WithFixedPoint()).Hash(img); err == nil {
+			t.Errorf("ExtPerceptionHash of %dx%d bits with fixed point is expected to fail", tt.width, tt.height)
+		}
+	}
+	for _, hasher := range []Hasher{NewExtAverageHasher(0, 8, WithFixedPoint()), NewExtDifferenceHasher(8, -1, WithFixedPoint())} {
+		if _, err := hasher.Hash(img); err == nil {
+			t.Errorf("hashes of no bits with fixed point are expected to fail")
+		}
+	}
+}
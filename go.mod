@@ -2,4 +2,3 @@ module github.com/corona10/goimagehash
 
 go 1.11
 
-require github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
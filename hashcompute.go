@@ -11,84 +11,186 @@ import (
 
 	"github.com/corona10/goimagehash/etcs"
 	"github.com/corona10/goimagehash/transforms"
-	"github.com/nfnt/resize"
 )
 
 // AverageHash function returns a hash computation of average hash.
 // Implementation follows
 // http://www.hackerfactor.com/blog/index.php?/archives/432-Looks-Like-It.html
+// NewAverageHasher computes it with options.
 func AverageHash(img image.Image) (*ImageHash, error) {
+	return averageHash(img, defaultOptions())
+}
+
+func averageHash(img image.Image, o *options) (*ImageHash, error) {
+	if err := o.canceled(); err != nil {
+		return nil, err
+	}
+	img = o.prepare(img)
+	if o.pythonCompat {
+		return pythonImageHash(pythonAverageHash(img, 8))
+	}
 	if img == nil {
 		return nil, errors.New("image object can not be nil")
 	}
+	if o.fixedPoint {
+		bits, err := fixedAverageBits(img, 8, 8, o)
+		if err != nil {
+			return nil, err
+		}
+		return NewImageHash(bits[0], AHash), nil
+	}
 
 	// Create 64bits hash.
-	ahash := NewImageHash(0, AHash)
-	resized := resize.Resize(8, 8, img, resize.Bilinear)
-	pixels := transforms.Rgb2Gray(resized)
-	flattens := transforms.FlattenPixels(pixels, 8, 8)
-	avg := etcs.MeanOfPixels(flattens)
+	pixels, err := o.grayscale(img, 8, 8)
+	if err != nil {
+		return nil, err
+	}
+	return NewImageHash(averageBits(pixels, 8, 8)[0], AHash), nil
+}
 
-	for idx, p := range flattens {
-		if p > avg {
-			ahash.leftShiftSet(len(flattens) - idx - 1)
-		}
+// averageBits returns the bits of the width x height gray scale values
+// brighter than their mean.
+func averageBits(pixels [][]float64, width, height int) []uint64 {
+	flattens := transforms.FlattenPixels(pixels, width, height)
+	return bitsAbove(flattens, etcs.MeanOfPixels(flattens))
+}
+
+// bitsAbove returns the bits of the values greater than threshold, packed
+// 64 by 64 from the most significant bit.
+func bitsAbove(values []float64, threshold float64) []uint64 {
+	lenOfUnit := 64
+	var bits []uint64
+	if len(values)%lenOfUnit == 0 {
+		bits = make([]uint64, len(values)/lenOfUnit)
+	} else {
+		bits = make([]uint64, len(values)/lenOfUnit+1)
 	}
+	putBitsAbove(bits, values, threshold)
+	return bits
+}
 
-	return ahash, nil
+// putBitsAbove sets the bits of the values greater than threshold into the
+// zeroed bits, see bitsAbove.
+func putBitsAbove(bits []uint64, values []float64, threshold float64) {
+	lenOfUnit := 64
+	for idx, p := range values {
+		indexOfArray := idx / lenOfUnit
+		indexOfBit := lenOfUnit - idx%lenOfUnit - 1
+		if p > threshold {
+			bits[indexOfArray] |= 1 << uint(indexOfBit)
+		}
+	}
 }
 
 // DifferenceHash function returns a hash computation of difference hash.
 // Implementation follows
 // http://www.hackerfactor.com/blog/?/archives/529-Kind-of-Like-That.html
+// NewDifferenceHasher computes it with options.
 func DifferenceHash(img image.Image) (*ImageHash, error) {
+	return differenceHash(img, defaultOptions())
+}
+
+func differenceHash(img image.Image, o *options) (*ImageHash, error) {
+	if err := o.canceled(); err != nil {
+		return nil, err
+	}
+	img = o.prepare(img)
+	if o.pythonCompat {
+		return pythonImageHash(pythonDifferenceHash(img, 8))
+	}
 	if img == nil {
 		return nil, errors.New("image object can not be nil")
 	}
+	if o.fixedPoint {
+		bits, err := fixedDifferenceBits(img, 8, 8, o)
+		if err != nil {
+			return nil, err
+		}
+		return NewImageHash(bits[0], DHash), nil
+	}
+
+	pixels, err := o.grayscale(img, 9, 8)
+	if err != nil {
+		return nil, err
+	}
+	return NewImageHash(differenceBits(pixels, 64)[0], DHash), nil
+}
 
-	dhash := NewImageHash(0, DHash)
-	resized := resize.Resize(9, 8, img, resize.Bilinear)
-	pixels := transforms.Rgb2Gray(resized)
+// differenceBits returns the bits of the gray scale values darker than their
+// right neighbour. Rows hold one value more than the bits they give.
+func differenceBits(pixels [][]float64, bits int) []uint64 {
+	lenOfUnit := 64
+	var dhash []uint64
+	if bits%lenOfUnit == 0 {
+		dhash = make([]uint64, bits/lenOfUnit)
+	} else {
+		dhash = make([]uint64, bits/lenOfUnit+1)
+	}
+	putDifferenceBits(dhash, pixels)
+	return dhash
+}
+
+// putDifferenceBits sets the bits of the gray scale values darker than their
+// right neighbour into the zeroed dhash, see differenceBits.
+func putDifferenceBits(dhash []uint64, pixels [][]float64) {
+	lenOfUnit := 64
 	idx := 0
 	for i := 0; i < len(pixels); i++ {
 		for j := 0; j < len(pixels[i])-1; j++ {
+			indexOfArray := idx / lenOfUnit
+			indexOfBit := lenOfUnit - idx%lenOfUnit - 1
 			if pixels[i][j] < pixels[i][j+1] {
-				dhash.leftShiftSet(64 - idx - 1)
+				dhash[indexOfArray] |= 1 << uint(indexOfBit)
 			}
 			idx++
 		}
 	}
-
-	return dhash, nil
 }
 
 // PerceptionHash function returns a hash computation of phash.
 // Implementation follows
 // http://www.hackerfactor.com/blog/index.php?/archives/432-Looks-Like-It.html
+// NewPerceptionHasher computes it with options.
 func PerceptionHash(img image.Image) (*ImageHash, error) {
+	return perceptionHash(img, defaultOptions())
+}
+
+func perceptionHash(img image.Image, o *options) (*ImageHash, error) {
+	if err := o.canceled(); err != nil {
+		return nil, err
+	}
+	img = o.prepare(img)
+	if o.pythonCompat {
+		return pythonImageHash(pythonPerceptionHash(img, 8))
+	}
 	if img == nil {
 		return nil, errors.New("image object can not be nil")
 	}
-
-	phash := NewImageHash(0, PHash)
-	resized := resize.Resize(64, 64, img, resize.Bilinear)
+	if o.fixedPoint {
+		bits, err := fixedPerceptionBits(img, 8, 8, o)
+		if err != nil {
+			return nil, err
+		}
+		return NewImageHash(bits[0], PHash), nil
+	}
 
 	pixels := pixelPool64.Get().(*[]float64)
 
-	transforms.Rgb2GrayFast(resized, pixels)
-	flattens := transforms.DCT2DFast64(pixels)
-
+	if err := o.resampler().Resample(img, *pixels, 64, 64); err != nil {
+		pixelPool64.Put(pixels)
+		return nil, o.failure(err)
+	}
+	phash := NewImageHash(perceptionBits64(pixels), PHash)
 	pixelPool64.Put(pixels)
+	return phash, nil
+}
 
+// perceptionBits64 returns the 64bits phash of 64x64 gray scale values,
+// which are overwritten.
+func perceptionBits64(pixels *[]float64) uint64 {
+	flattens := transforms.DCT2DFast64(pixels)
 	median := etcs.MedianOfPixelsFast64(flattens[:])
-
-	for idx, p := range flattens {
-		if p > median {
-			phash.leftShiftSet(64 - idx - 1) // leftShiftSet
-		}
-	}
-
-	return phash, nil
+	return bitsAbove(flattens[:], median)[0]
 }
 
 var pixelPool64 = sync.Pool{
@@ -98,100 +200,194 @@ var pixelPool64 = sync.Pool{
 	},
 }
 
+// pixelPools holds the size x size buffers of ExtPerceptionHash for the sizes
+// supported by transforms.DCT2DFast, indexed by the log2 of the size.
+var pixelPools [10]sync.Pool
+
+func init() {
+	for i := range pixelPools {
+		size := 1 << uint(i)
+		pixelPools[i].New = func() interface{} {
+			p := make([]float64, size*size)
+			return &p
+		}
+	}
+}
+
+// flattenCoefficients returns the width x height coefficients stored row by
+// row ordered as transforms.FlattenPixels orders them.
+func flattenCoefficients(coeffs []float64, width, height int) []float64 {
+	if width == height {
+		return coeffs
+	}
+	flattens := make([]float64, width*height)
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			flattens[height*i+j] = coeffs[i*width+j]
+		}
+	}
+	return flattens
+}
+
 // ExtPerceptionHash function returns phash of which the size can be set larger than uint64
 // Some variable name refer to https://github.com/JohannesBuchner/imagehash/blob/master/imagehash/__init__.py
 // Support 64bits phash (width=8, height=8) and 256bits phash (width=16, height=16)
 // Important: width * height should be the power of 2
+// NewExtPerceptionHasher computes it with options.
 func ExtPerceptionHash(img image.Image, width, height int) (*ExtImageHash, error) {
+	return extPerceptionHash(img, width, height, defaultOptions())
+}
+
+func extPerceptionHash(img image.Image, width, height int, o *options) (*ExtImageHash, error) {
+	if err := o.canceled(); err != nil {
+		return nil, err
+	}
+	img = o.prepare(img)
+	if o.pythonCompat {
+		hashSize, err := pythonHashSize(width, height)
+		if err != nil {
+			return nil, err
+		}
+		return pythonPerceptionHash(img, hashSize)
+	}
 	imgSize := width * height
 	if img == nil {
 		return nil, errors.New("image object can not be nil")
 	}
+	if o.fixedPoint {
+		bits, err := fixedPerceptionBits(img, width, height, o)
+		if err != nil {
+			return nil, err
+		}
+		return NewExtImageHash(bits, PHash, width*height), nil
+	}
 	if imgSize <= 0 || imgSize&(imgSize-1) != 0 {
 		return nil, errors.New("width * height should be power of 2")
 	}
-	var phash []uint64
-	resized := resize.Resize(uint(imgSize), uint(imgSize), img, resize.Bilinear)
-	pixels := transforms.Rgb2Gray(resized)
-	dct := transforms.DCT2D(pixels, imgSize, imgSize)
-	flattens := transforms.FlattenPixels(dct, width, height)
-	median := etcs.MedianOfPixels(flattens)
+	pixels := perceptionBuffer(imgSize)
+	if err := o.resampler().Resample(img, *pixels, imgSize, imgSize); err != nil {
+		putPerceptionBuffer(pixels, imgSize)
+		return nil, o.failure(err)
+	}
+	phash, err := perceptionBits(*pixels, width, height, o)
+	putPerceptionBuffer(pixels, imgSize)
+	if err != nil {
+		return nil, err
+	}
+	return NewExtImageHash(phash, PHash, imgSize), nil
+}
 
-	lenOfUnit := 64
-	if imgSize%lenOfUnit == 0 {
-		phash = make([]uint64, imgSize/lenOfUnit)
-	} else {
-		phash = make([]uint64, imgSize/lenOfUnit+1)
+// perceptionBuffer returns a buffer of size x size values, pooled when size
+// is supported by transforms.DCT2DFast.
+func perceptionBuffer(size int) *[]float64 {
+	if transforms.HasDCT2DFast(size) {
+		return pixelPools[log2(size)].Get().(*[]float64)
 	}
-	for idx, p := range flattens {
-		indexOfArray := idx / lenOfUnit
-		indexOfBit := lenOfUnit - idx%lenOfUnit - 1
-		if p > median {
-			phash[indexOfArray] |= 1 << uint(indexOfBit)
+	p := make([]float64, size*size)
+	return &p
+}
+
+// putPerceptionBuffer returns a buffer of perceptionBuffer to its pool.
+func putPerceptionBuffer(pixels *[]float64, size int) {
+	if transforms.HasDCT2DFast(size) {
+		pixelPools[log2(size)].Put(pixels)
+	}
+}
+
+// perceptionBits returns the width x height bits phash of the gray scale
+// values of a square of width * height pixels, which are overwritten.
+func perceptionBits(pixels []float64, width, height int, o *options) ([]uint64, error) {
+	imgSize := width * height
+	var flattens []float64
+	if transforms.HasDCT2DFast(imgSize) {
+		coeffs := make([]float64, width*height)
+		if err := transforms.DCT2DFast(pixels, imgSize, width, height, coeffs); err != nil {
+			return nil, err
+		}
+		flattens = flattenCoefficients(coeffs, width, height)
+	} else {
+		rows := make([][]float64, imgSize)
+		for i := range rows {
+			rows[i] = pixels[i*imgSize : (i+1)*imgSize]
+		}
+		dct := transforms.DCT{Workers: o.parallelism, Done: o.done}
+		if err := dct.Transform2D(rows, imgSize, imgSize); err != nil {
+			return nil, o.failure(err)
 		}
+		flattens = transforms.FlattenPixels(rows, width, height)
 	}
-	return NewExtImageHash(phash, PHash, imgSize), nil
+	return bitsAbove(flattens, etcs.MedianOfPixels(flattens)), nil
 }
 
 // ExtAverageHash function returns ahash of which the size can be set larger than uint64
 // Support 64bits ahash (width=8, height=8) and 256bits ahash (width=16, height=16)
+// NewExtAverageHasher computes it with options.
 func ExtAverageHash(img image.Image, width, height int) (*ExtImageHash, error) {
+	return extAverageHash(img, width, height, defaultOptions())
+}
+
+func extAverageHash(img image.Image, width, height int, o *options) (*ExtImageHash, error) {
+	if err := o.canceled(); err != nil {
+		return nil, err
+	}
+	img = o.prepare(img)
+	if o.pythonCompat {
+		hashSize, err := pythonHashSize(width, height)
+		if err != nil {
+			return nil, err
+		}
+		return pythonAverageHash(img, hashSize)
+	}
 	if img == nil {
 		return nil, errors.New("image object can not be nil")
 	}
-	var ahash []uint64
-	imgSize := width * height
-
-	resized := resize.Resize(uint(width), uint(height), img, resize.Bilinear)
-	pixels := transforms.Rgb2Gray(resized)
-	flattens := transforms.FlattenPixels(pixels, width, height)
-	avg := etcs.MeanOfPixels(flattens)
-
-	lenOfUnit := 64
-	if imgSize%lenOfUnit == 0 {
-		ahash = make([]uint64, imgSize/lenOfUnit)
-	} else {
-		ahash = make([]uint64, imgSize/lenOfUnit+1)
-	}
-	for idx, p := range flattens {
-		indexOfArray := idx / lenOfUnit
-		indexOfBit := lenOfUnit - idx%lenOfUnit - 1
-		if p > avg {
-			ahash[indexOfArray] |= 1 << uint(indexOfBit)
+	if o.fixedPoint {
+		bits, err := fixedAverageBits(img, width, height, o)
+		if err != nil {
+			return nil, err
 		}
+		return NewExtImageHash(bits, AHash, width*height), nil
+	}
+	pixels, err := o.grayscale(img, width, height)
+	if err != nil {
+		return nil, err
 	}
-	return NewExtImageHash(ahash, AHash, imgSize), nil
+	return NewExtImageHash(averageBits(pixels, width, height), AHash, width*height), nil
 }
 
 // ExtDifferenceHash function returns dhash of which the size can be set larger than uint64
 // Support 64bits dhash (width=8, height=8) and 256bits dhash (width=16, height=16)
+// NewExtDifferenceHasher computes it with options.
 func ExtDifferenceHash(img image.Image, width, height int) (*ExtImageHash, error) {
+	return extDifferenceHash(img, width, height, defaultOptions())
+}
+
+func extDifferenceHash(img image.Image, width, height int, o *options) (*ExtImageHash, error) {
+	if err := o.canceled(); err != nil {
+		return nil, err
+	}
+	img = o.prepare(img)
+	if o.pythonCompat {
+		hashSize, err := pythonHashSize(width, height)
+		if err != nil {
+			return nil, err
+		}
+		return pythonDifferenceHash(img, hashSize)
+	}
 	if img == nil {
 		return nil, errors.New("image object can not be nil")
 	}
 
-	var dhash []uint64
-	imgSize := width * height
-
-	resized := resize.Resize(uint(width)+1, uint(height), img, resize.Bilinear)
-	pixels := transforms.Rgb2Gray(resized)
-
-	lenOfUnit := 64
-	if imgSize%lenOfUnit == 0 {
-		dhash = make([]uint64, imgSize/lenOfUnit)
-	} else {
-		dhash = make([]uint64, imgSize/lenOfUnit+1)
-	}
-	idx := 0
-	for i := 0; i < len(pixels); i++ {
-		for j := 0; j < len(pixels[i])-1; j++ {
-			indexOfArray := idx / lenOfUnit
-			indexOfBit := lenOfUnit - idx%lenOfUnit - 1
-			if pixels[i][j] < pixels[i][j+1] {
-				dhash[indexOfArray] |= 1 << uint(indexOfBit)
-			}
-			idx++
+	if o.fixedPoint {
+		bits, err := fixedDifferenceBits(img, width, height, o)
+		if err != nil {
+			return nil, err
 		}
+		return NewExtImageHash(bits, DHash, width*height), nil
+	}
+	pixels, err := o.grayscale(img, width+1, height)
+	if err != nil {
+		return nil, err
 	}
-	return NewExtImageHash(dhash, DHash, imgSize), nil
+	return NewExtImageHash(differenceBits(pixels, width*height), DHash, width*height), nil
 }
@@ -9,6 +9,9 @@ import (
 	"image/jpeg"
 	"os"
 	"testing"
+
+	"github.com/corona10/goimagehash/etcs"
+	"github.com/corona10/goimagehash/transforms"
 )
 
 func TestHashCompute(t *testing.T) {
@@ -393,3 +396,82 @@ func BenchmarkDifferenceHash(b *testing.B) {
 		}
 	}
 }
+
+// extPerceptionHashDCT2D computes ExtPerceptionHash with transforms.DCT2D,
+// as it did before the static DCT fast path.
+func extPerceptionHashDCT2D(img image.Image, width, height int) (*ExtImageHash, error) {
+	imgSize := width * height
+	pixels, err := defaultOptions().grayscale(img, imgSize, imgSize)
+	if err != nil {
+		return nil, err
+	}
+	dct := transforms.DCT2D(pixels, imgSize, imgSize)
+	flattens := transforms.FlattenPixels(dct, width, height)
+	median := etcs.MedianOfPixels(flattens)
+	phash := make([]uint64, (imgSize+63)/64)
+	for idx, p := range flattens {
+		if p > median {
+			phash[idx/64] |= 1 << uint(63-idx%64)
+		}
+	}
+	return NewExtImageHash(phash, PHash, imgSize), nil
+}
+
+func TestExtPerceptionHashFastDCT(t *testing.T) {
+	img := loadImage(t, "_examples/sample1.jpg")
+	for _, size := range [][2]int{{2, 2}, {4, 2}, {8, 4}, {8, 8}, {16, 8}, {16, 16}, {32, 16}} {
+		expected, err := extPerceptionHashDCT2D(img, size[0], size[1])
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		hash, err := ExtPerceptionHash(img, size[0], size[1])
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if hash.ToString() != expected.ToString() {
+			t.Errorf("ExtPerceptionHash(%d, %d) is expected %s but got %s", size[0], size[1], expected.ToString(), hash.ToString())
+		}
+	}
+}
+
+func benchmarkExtPerceptionHash(b *testing.B, width, height int, fn func(image.Image, int, int) (*ExtImageHash, error)) {
+	img := loadImage(b, "_examples/sample3.jpg")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fn(img, width, height); err != nil {
+			b.Errorf("%s", err)
+		}
+	}
+}
+
+func BenchmarkExtPerceptionHash8x4(b *testing.B) {
+	benchmarkExtPerceptionHash(b, 8, 4, ExtPerceptionHash)
+}
+
+func BenchmarkExtPerceptionHash8x4DCT2D(b *testing.B) {
+	benchmarkExtPerceptionHash(b, 8, 4, extPerceptionHashDCT2D)
+}
+
+func BenchmarkExtPerceptionHash16x8(b *testing.B) {
+	benchmarkExtPerceptionHash(b, 16, 8, ExtPerceptionHash)
+}
+
+func BenchmarkExtPerceptionHash16x8DCT2D(b *testing.B) {
+	benchmarkExtPerceptionHash(b, 16, 8, extPerceptionHashDCT2D)
+}
+
+func BenchmarkExtPerceptionHash16x16(b *testing.B) {
+	benchmarkExtPerceptionHash(b, 16, 16, ExtPerceptionHash)
+}
+
+func BenchmarkExtPerceptionHash16x16DCT2D(b *testing.B) {
+	benchmarkExtPerceptionHash(b, 16, 16, extPerceptionHashDCT2D)
+}
+
+func BenchmarkExtPerceptionHash32x16(b *testing.B) {
+	benchmarkExtPerceptionHash(b, 32, 16, ExtPerceptionHash)
+}
+
+func BenchmarkExtPerceptionHash32x16DCT2D(b *testing.B) {
+	benchmarkExtPerceptionHash(b, 32, 16, extPerceptionHashDCT2D)
+}
@@ -0,0 +1,250 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/corona10/goimagehash/transforms"
+)
+
+// Hash is the common interface of ImageHash and ExtImageHash.
+type Hash interface {
+	// GetKind method returns a kind of hash.
+	GetKind() Kind
+	// Bits method returns an actual hash bit size.
+	Bits() int
+	// ToString method returns a hex representation of the hash.
+	ToString() string
+	// Dump method writes a binary serialization into w io.Writer.
+	Dump(w io.Writer) error
+}
+
+// Hasher computes a single kind of hash of an image.
+type Hasher interface {
+	// Kind method returns the kind of the hashes created by Hash.
+	Kind() Kind
+	// Hash method returns a hash computation of img.
+	Hash(img image.Image) (Hash, error)
+}
+
+// imageHasher is a Hasher returning 64bits hashes.
+type imageHasher struct {
+	kind Kind
+	opts *options
+	fn   func(img image.Image, o *options) (*ImageHash, error)
+}
+
+func (h *imageHasher) Kind() Kind {
+	return h.kind
+}
+
+func (h *imageHasher) Hash(img image.Image) (Hash, error) {
+	hash, err := h.fn(img, h.opts)
+	if err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// extImageHasher is a Hasher returning hashes of width * height bits.
+type extImageHasher struct {
+	kind          Kind
+	width, height int
+	opts          *options
+	fn            func(img image.Image, width, height int, o *options) (*ExtImageHash, error)
+}
+
+func (h *extImageHasher) Kind() Kind {
+	return h.kind
+}
+
+func (h *extImageHasher) Hash(img image.Image) (Hash, error) {
+	hash, err := h.fn(img, h.width, h.height, h.opts)
+	if err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// NewAverageHasher function returns a Hasher computing AverageHash configured by opts.
+func NewAverageHasher(opts ...Option) Hasher {
+	return &imageHasher{kind: AHash, opts: newOptions(opts), fn: averageHash}
+}
+
+// NewDifferenceHasher function returns a Hasher computing DifferenceHash configured by opts.
+func NewDifferenceHasher(opts ...Option) Hasher {
+	return &imageHasher{kind: DHash, opts: newOptions(opts), fn: differenceHash}
+}
+
+// NewPerceptionHasher function returns a Hasher computing PerceptionHash configured by opts.
+func NewPerceptionHasher(opts ...Option) Hasher {
+	return &imageHasher{kind: PHash, opts: newOptions(opts), fn: perceptionHash}
+}
+
+// NewExtAverageHasher function returns a Hasher computing ExtAverageHash of the given size configured by opts.
+func NewExtAverageHasher(width, height int, opts ...Option) Hasher {
+	return &extImageHasher{kind: AHash, width: width, height: height, opts: newOptions(opts), fn: extAverageHash}
+}
+
+// NewExtDifferenceHasher function returns a Hasher computing ExtDifferenceHash of the given size configured by opts.
+func NewExtDifferenceHasher(width, height int, opts ...Option) Hasher {
+	return &extImageHasher{kind: DHash, width: width, height: height, opts: newOptions(opts), fn: extDifferenceHash}
+}
+
+// NewExtPerceptionHasher function returns a Hasher computing ExtPerceptionHash of the given size configured by opts.
+func NewExtPerceptionHasher(width, height int, opts ...Option) Hasher {
+	return &extImageHasher{kind: PHash, width: width, height: height, opts: newOptions(opts), fn: extPerceptionHash}
+}
+
+// NewWaveletHasher function returns a Hasher computing a 64bits wavelet hash.
+// Wavelet hashes follow imagehash.whash of the Python imagehash library,
+// as if WithPythonCompat was always given.
+func NewWaveletHasher(opts ...Option) Hasher {
+	return &imageHasher{kind: WHash, opts: newOptions(opts), fn: waveletHash}
+}
+
+// NewExtWaveletHasher function returns a Hasher computing a wavelet hash of the given size.
+// The hash should be square and its width a power of 2.
+func NewExtWaveletHasher(width, height int, opts ...Option) Hasher {
+	return &extImageHasher{kind: WHash, width: width, height: height, opts: newOptions(opts), fn: extWaveletHash}
+}
+
+func waveletHash(img image.Image, o *options) (*ImageHash, error) {
+	if err := o.canceled(); err != nil {
+		return nil, err
+	}
+	img = o.prepare(img)
+	return pythonImageHash(pythonWaveletHash(img, 8))
+}
+
+func extWaveletHash(img image.Image, width, height int, o *options) (*ExtImageHash, error) {
+	if err := o.canceled(); err != nil {
+		return nil, err
+	}
+	img = o.prepare(img)
+	hashSize, err := pythonHashSize(width, height)
+	if err != nil {
+		return nil, err
+	}
+	return pythonWaveletHash(img, hashSize)
+}
+
+var (
+	hasherMu sync.RWMutex
+	hashers  = map[string]func() Hasher{}
+	// builtinHashers maps kind names to constructors of 64bits and extended hashers.
+	builtinHashers = map[string]struct {
+		simple func(opts ...Option) Hasher
+		ext    func(width, height int, opts ...Option) Hasher
+	}{
+		"ahash": {NewAverageHasher, NewExtAverageHasher},
+		"dhash": {NewDifferenceHasher, NewExtDifferenceHasher},
+		"phash": {NewPerceptionHasher, NewExtPerceptionHasher},
+		"whash": {NewWaveletHasher, NewExtWaveletHasher},
+	}
+)
+
+// HashRegion function returns the hash by hasher of the part of img inside
+// rect, as if it was cropped to a new image. The pixels are not copied.
+func HashRegion(img image.Image, rect image.Rectangle, hasher Hasher) (Hash, error) {
+	if img == nil {
+		return nil, errors.New("image object can not be nil")
+	}
+	if rect.Intersect(img.Bounds()).Empty() {
+		return nil, fmt.Errorf("region %v is outside of the image bounds %v", rect, img.Bounds())
+	}
+	return hasher.Hash(transforms.Crop(img, rect))
+}
+
+// RegisterHasher function registers a Hasher constructor under name so that
+// LookupHasher can find it. Registered names take precedence over built-in ones.
+func RegisterHasher(name string, fn func() Hasher) error {
+	if name == "" {
+		return errors.New("hasher name can not be empty")
+	}
+	if fn == nil {
+		return errors.New("hasher constructor can not be nil")
+	}
+	hasherMu.Lock()
+	defer hasherMu.Unlock()
+	if _, ok := hashers[name]; ok {
+		return fmt.Errorf("hasher %q is already registered", name)
+	}
+	hashers[name] = fn
+	return nil
+}
+
+// LookupHasher function returns a Hasher by name.
+// Besides names registered with RegisterHasher, built-in hashers are named
+// after their kind ("ahash", "dhash", "phash", "whash") optionally followed by the
+// bit size ("phash-256") or the width and height ("ahash-16x8").
+// A bit size must be a square number and 64 selects the 64bits hash functions.
+// opts are applied to built-in hashers only.
+func LookupHasher(name string, opts ...Option) (Hasher, error) {
+	hasherMu.RLock()
+	fn, ok := hashers[name]
+	hasherMu.RUnlock()
+	if ok {
+		return fn(), nil
+	}
+
+	kindName, size := name, ""
+	i := strings.IndexByte(name, '-')
+	if i >= 0 {
+		kindName, size = name[:i], name[i+1:]
+	}
+	builtin, ok := builtinHashers[kindName]
+	if !ok {
+		return nil, errors.New("unknown hasher " + name)
+	}
+	if i < 0 {
+		return builtin.simple(opts...), nil
+	}
+
+	width, height, err := parseHasherSize(size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hasher %q: %v", name, err)
+	}
+	if strings.IndexByte(size, 'x') < 0 && width*height == 64 {
+		return builtin.simple(opts...), nil
+	}
+	return builtin.ext(width, height, opts...), nil
+}
+
+// parseHasherSize parses either a square bit size ("256") or "WxH" ("16x8").
+func parseHasherSize(size string) (width, height int, err error) {
+	if i := strings.IndexByte(size, 'x'); i >= 0 {
+		width, err = strconv.Atoi(size[:i])
+		if err != nil {
+			return 0, 0, err
+		}
+		height, err = strconv.Atoi(size[i+1:])
+		if err != nil {
+			return 0, 0, err
+		}
+	} else {
+		bits, err := strconv.Atoi(size)
+		if err != nil {
+			return 0, 0, err
+		}
+		width = int(math.Sqrt(float64(bits)))
+		if width*width != bits {
+			return 0, 0, fmt.Errorf("bit size %d is not a square number", bits)
+		}
+		height = width
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, errors.New("width and height should be positive")
+	}
+	return width, height, nil
+}
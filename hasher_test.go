@@ -0,0 +1,215 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"testing"
+)
+
+func TestLookupHasher(t *testing.T) {
+	file, err := os.Open("_examples/sample1.jpg")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer file.Close()
+	img, err := jpeg.Decode(file)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		kind     Kind
+		bits     int
+		expected func(img image.Image) (Hash, error)
+	}{
+		{"ahash", AHash, 64, func(img image.Image) (Hash, error) { return AverageHash(img) }},
+		{"dhash", DHash, 64, func(img image.Image) (Hash, error) { return DifferenceHash(img) }},
+		{"phash", PHash, 64, func(img image.Image) (Hash, error) { return PerceptionHash(img) }},
+		{"phash-64", PHash, 64, func(img image.Image) (Hash, error) { return PerceptionHash(img) }},
+		{"ahash-256", AHash, 256, func(img image.Image) (Hash, error) { return ExtAverageHash(img, 16, 16) }},
+		{"dhash-1024", DHash, 1024, func(img image.Image) (Hash, error) { return ExtDifferenceHash(img, 32, 32) }},
+		{"phash-256", PHash, 256, func(img image.Image) (Hash, error) { return ExtPerceptionHash(img, 16, 16) }},
+		{"phash-8x8", PHash, 64, func(img image.Image) (Hash, error) { return ExtPerceptionHash(img, 8, 8) }},
+		{"ahash-16x8", AHash, 128, func(img image.Image) (Hash, error) { return ExtAverageHash(img, 16, 8) }},
+	} {
+		hasher, err := LookupHasher(tt.name)
+		if err != nil {
+			t.Errorf("LookupHasher(%q): %v", tt.name, err)
+			continue
+		}
+		if hasher.Kind() != tt.kind {
+			t.Errorf("LookupHasher(%q) kind is expected %v but got %v", tt.name, tt.kind, hasher.Kind())
+		}
+		hash, err := hasher.Hash(img)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		expected, _ := tt.expected(img)
+		if hash.GetKind() != tt.kind || hash.Bits() != tt.bits {
+			t.Errorf("%s: got kind %v and %d bits", tt.name, hash.GetKind(), hash.Bits())
+		}
+		if hash.ToString() != expected.ToString() {
+			t.Errorf("%s: hash is expected %v but got %v", tt.name, expected.ToString(), hash.ToString())
+		}
+	}
+
+	for _, name := range []string{"", "xhash", "ahash-", "ahash-100x", "ahash-63", "ahash-0", "ahash-0x8", "ahash-axb"} {
+		if _, err := LookupHasher(name); err == nil {
+			t.Errorf("LookupHasher(%q) should return an error", name)
+		}
+	}
+}
+
+func TestHasherError(t *testing.T) {
+	hash, err := NewAverageHasher().Hash(nil)
+	if err == nil {
+		t.Errorf("Error should be got.")
+	}
+	if hash != nil {
+		t.Errorf("Nil hash should be got. but got %v", hash)
+	}
+
+	hash, err = NewExtPerceptionHasher(8, 9).Hash(image.NewGray(image.Rect(0, 0, 8, 8)))
+	if err == nil {
+		t.Errorf("Error should be got.")
+	}
+	if hash != nil {
+		t.Errorf("Nil hash should be got. but got %v", hash)
+	}
+}
+
+type constHasher struct{}
+
+func (constHasher) Kind() Kind { return AHash }
+
+func (constHasher) Hash(img image.Image) (Hash, error) { return NewImageHash(1, AHash), nil }
+
+func TestRegisterHasher(t *testing.T) {
+	if err := RegisterHasher("const", func() Hasher { return constHasher{} }); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := RegisterHasher("const", func() Hasher { return constHasher{} }); err == nil {
+		t.Errorf("Should got error for a duplicated hasher name")
+	}
+	if err := RegisterHasher("", func() Hasher { return constHasher{} }); err == nil {
+		t.Errorf("Should got error for an empty hasher name")
+	}
+	if err := RegisterHasher("nil", nil); err == nil {
+		t.Errorf("Should got error for a nil constructor")
+	}
+
+	hasher, err := LookupHasher("const")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, ok := hasher.(constHasher); !ok {
+		t.Errorf("LookupHasher should return the registered hasher but got %T", hasher)
+	}
+}
+
+// regionHashers returns hashers covering every hash function.
+func regionHashers(t *testing.T) map[string]Hasher {
+	hashers := map[string]Hasher{}
+	for _, name := range []string{"ahash", "dhash", "phash", "whash", "ahash-256", "dhash-256", "phash-256", "whash-256"} {
+		hasher, err := LookupHasher(name)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		hashers[name] = hasher
+	}
+	hashers["python ahash"] = NewAverageHasher(WithPythonCompat())
+	hashers["python dhash"] = NewDifferenceHasher(WithPythonCompat())
+	hashers["python phash"] = NewPerceptionHasher(WithPythonCompat())
+	hashers["trimmed ahash"] = NewAverageHasher(WithTrim(nil, 8))
+	return hashers
+}
+
+func TestHashRegion(t *testing.T) {
+	src := loadImage(t, "_examples/sample1.jpg")
+	img := image.NewRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	rect := image.Rect(37, 21, 201, 170)
+	cropped := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), img, rect.Min, draw.Src)
+
+	for name, hasher := range regionHashers(t) {
+		expected, err := hasher.Hash(cropped)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		for _, region := range []image.Image{img, struct{ image.Image }{img}} {
+			hash, err := HashRegion(region, rect, hasher)
+			if err != nil {
+				t.Errorf("%s: %s", name, err)
+				continue
+			}
+			if hash.ToString() != expected.ToString() {
+				t.Errorf("%s of a region of %T is expected %s but got %s", name, region, expected.ToString(), hash.ToString())
+			}
+		}
+		hash, err := hasher.Hash(img.SubImage(rect))
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+		} else if hash.ToString() != expected.ToString() {
+			t.Errorf("%s of a sub-image is expected %s but got %s", name, expected.ToString(), hash.ToString())
+		}
+	}
+
+	if _, err := HashRegion(img, image.Rect(300, 300, 400, 400), NewAverageHasher()); err == nil {
+		t.Errorf("HashRegion outside of the image is expected to fail")
+	}
+}
+
+func TestHashTranslatedImage(t *testing.T) {
+	src := loadImage(t, "_examples/sample1.jpg")
+	ycbcr, ok := src.(*image.YCbCr)
+	if !ok {
+		t.Fatalf("sample1.jpg is expected to be decoded as *image.YCbCr but got %T", src)
+	}
+	rgba := image.NewRGBA(ycbcr.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), ycbcr, ycbcr.Bounds().Min, draw.Src)
+	gray := image.NewGray(ycbcr.Bounds())
+	draw.Draw(gray, gray.Bounds(), ycbcr, ycbcr.Bounds().Min, draw.Src)
+
+	// Moving the bounds of an image translates its pixels.
+	offset := image.Pt(-13, 42)
+	translatedYCbCr := *ycbcr
+	translatedYCbCr.Rect = ycbcr.Rect.Add(offset)
+	translatedRGBA := *rgba
+	translatedRGBA.Rect = rgba.Rect.Add(offset)
+	translatedGray := *gray
+	translatedGray.Rect = gray.Rect.Add(offset)
+
+	for name, hasher := range regionHashers(t) {
+		for _, tt := range []struct {
+			img, translated image.Image
+		}{
+			{ycbcr, &translatedYCbCr},
+			{rgba, &translatedRGBA},
+			{gray, &translatedGray},
+			{rgba, struct{ image.Image }{&translatedRGBA}},
+		} {
+			expected, err := hasher.Hash(tt.img)
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			hash, err := hasher.Hash(tt.translated)
+			if err != nil {
+				t.Errorf("%s: %s", name, err)
+				continue
+			}
+			if hash.ToString() != expected.ToString() {
+				t.Errorf("%s of a translated %T is expected %s but got %s", name, tt.translated, expected.ToString(), hash.ToString())
+			}
+		}
+	}
+}
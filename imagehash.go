@@ -16,6 +16,7 @@ import (
 var errNoOther = errors.New("other should not be nil")
 
 // Kind describes the kinds of hash.
+// Additional kinds can be added with RegisterKind.
 type Kind int
 
 // ImageHash is a struct of hash computation.
@@ -112,6 +113,9 @@ func LoadImageHash(b io.Reader) (*ImageHash, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !e.Kind.registered() {
+		return nil, fmt.Errorf("unregistered hash kind %d", e.Kind)
+	}
 	return &ImageHash{hash: e.Hash, kind: e.Kind}, nil
 }
 
@@ -126,33 +130,13 @@ func ImageHashFromString(s string) (*ImageHash, error) {
 		return nil, errors.New("Couldn't parse string " + s)
 	}
 
-	kind := Unknown
-	switch kindStr {
-	case "a":
-		kind = AHash
-	case "p":
-		kind = PHash
-	case "d":
-		kind = DHash
-	case "w":
-		kind = WHash
-	}
+	kind := kindFromShortCode(kindStr)
 	return NewImageHash(hash, kind), nil
 }
 
 // ToString returns a hex representation of the hash
 func (h *ImageHash) ToString() string {
-	kindStr := ""
-	switch h.kind {
-	case AHash:
-		kindStr = "a"
-	case PHash:
-		kindStr = "p"
-	case DHash:
-		kindStr = "d"
-	case WHash:
-		kindStr = "w"
-	}
+	kindStr := h.kind.shortCode()
 	return fmt.Sprintf(strFmt, kindStr, h.hash)
 }
 
@@ -230,6 +214,9 @@ func LoadExtImageHash(b io.Reader) (*ExtImageHash, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !e.Kind.registered() {
+		return nil, fmt.Errorf("unregistered hash kind %d", e.Kind)
+	}
 	return &ExtImageHash{hash: e.Hash, kind: e.Kind, bits: e.Bits}, nil
 }
 
@@ -260,17 +247,7 @@ func ExtImageHashFromString(s string) (*ExtImageHash, error) {
 		hash = append(hash, hashUint64)
 	}
 
-	kind := Unknown
-	switch kindStr {
-	case "a":
-		kind = AHash
-	case "p":
-		kind = PHash
-	case "d":
-		kind = DHash
-	case "w":
-		kind = WHash
-	}
+	kind := kindFromShortCode(kindStr)
 	return NewExtImageHash(hash, kind, len(hash)*64), nil
 }
 
@@ -284,16 +261,6 @@ func (h *ExtImageHash) ToString() string {
 	}
 	hexStr := hex.EncodeToString(hexBytes)
 
-	kindStr := ""
-	switch h.kind {
-	case AHash:
-		kindStr = "a"
-	case PHash:
-		kindStr = "p"
-	case DHash:
-		kindStr = "d"
-	case WHash:
-		kindStr = "w"
-	}
+	kindStr := h.kind.shortCode()
 	return fmt.Sprintf(extStrFmt, kindStr, hexStr)
 }
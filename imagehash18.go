@@ -3,11 +3,10 @@
 package goimagehash
 
 func popcnt(x uint64) int {
-	diff := 0
-	for x != 0 {
-		diff += int(x & 1)
-		x >>= 1
-	}
-
-	return diff
+	x -= (x >> 1) & 0x5555555555555555
+	x = (x>>2)&0x3333333333333333 + x&0x3333333333333333
+	x += x >> 4
+	x &= 0x0f0f0f0f0f0f0f0f
+	x *= 0x0101010101010101
+	return int(x >> 56)
 }
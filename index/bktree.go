@@ -0,0 +1,114 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"github.com/corona10/goimagehash"
+)
+
+// BKTree is a Burkhard-Keller tree of hashes. Each node keeps its children
+// by their distance to it, so that the triangle inequality prunes the
+// subtrees which can not hold a hash close enough to a queried one.
+// The hashes of a tree should all be of the same type, kind and size.
+// A BKTree should not be modified while it is queried.
+type BKTree struct {
+	root *bkNode
+	size int
+}
+
+// bkNode holds the items of a hash and its children by distance.
+type bkNode struct {
+	hash     goimagehash.Hash
+	ids      []uint64
+	children map[int]*bkNode
+}
+
+// NewBKTree function returns an empty BKTree.
+func NewBKTree() *BKTree {
+	return &BKTree{}
+}
+
+// Len method returns the number of items in the tree.
+func (t *BKTree) Len() int {
+	return t.size
+}
+
+// Add method inserts the hash of the image id. Identical hashes share a node.
+func (t *BKTree) Add(id uint64, hash goimagehash.Hash) error {
+	if hash == nil {
+		return errNilHash
+	}
+	if t.root == nil {
+		if _, err := distance(hash, hash); err != nil {
+			return err
+		}
+		t.root = &bkNode{hash: hash, ids: []uint64{id}}
+		t.size++
+		return nil
+	}
+	node := t.root
+	for {
+		d, err := distance(node.hash, hash)
+		if err != nil {
+			return err
+		}
+		if d == 0 {
+			node.ids = append(node.ids, id)
+			t.size++
+			return nil
+		}
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = map[int]*bkNode{}
+			}
+			node.children[d] = &bkNode{hash: hash, ids: []uint64{id}}
+			t.size++
+			return nil
+		}
+		node = child
+	}
+}
+
+// RangeQuery method returns the items at most maxDistance away from hash,
+// sorted by distance then by ID.
+func (t *BKTree) RangeQuery(hash goimagehash.Hash, maxDistance int) ([]Match, error) {
+	if hash == nil {
+		return nil, errNilHash
+	}
+	var found []Match
+	if t.root == nil {
+		return found, nil
+	}
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d, err := distance(node.hash, hash)
+		if err != nil {
+			return nil, err
+		}
+		if d <= maxDistance {
+			for _, id := range node.ids {
+				found = append(found, Match{Item{id, node.hash}, d})
+			}
+		}
+		// The children at k from node are at least |d - k| away from hash.
+		if len(node.children) > 2*maxDistance+1 {
+			for k := d - maxDistance; k <= d+maxDistance; k++ {
+				if child, ok := node.children[k]; ok {
+					stack = append(stack, child)
+				}
+			}
+		} else {
+			for k, child := range node.children {
+				if k >= d-maxDistance && k <= d+maxDistance {
+					stack = append(stack, child)
+				}
+			}
+		}
+	}
+	return sortMatches(found), nil
+}
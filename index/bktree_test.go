@@ -0,0 +1,83 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"testing"
+
+	"github.com/corona10/goimagehash"
+)
+
+func TestBKTree(t *testing.T) {
+	for _, bits := range []int{64, 256} {
+		items := randomItems(2000, bits, int64(bits))
+		// Duplicated hashes share a node but keep their IDs.
+		items = append(items, Item{1, items[5].Hash}, Item{2, items[5].Hash})
+		tree := NewBKTree()
+		for _, item := range items {
+			if err := tree.Add(item.ID, item.Hash); err != nil {
+				t.Fatalf("%s", err)
+			}
+		}
+		if tree.Len() != len(items) {
+			t.Errorf("BKTree is expected to hold %d items but holds %d", len(items), tree.Len())
+		}
+		queries := append(randomItems(20, bits, int64(bits)+1), items[5], items[100])
+		for _, query := range queries {
+			for _, maxDistance := range []int{0, 3, bits / 16, bits / 4} {
+				found, err := tree.RangeQuery(query.Hash, maxDistance)
+				if err != nil {
+					t.Fatalf("%s", err)
+				}
+				if expected := bruteForce(t, items, query.Hash, maxDistance); !sameMatches(found, expected) {
+					t.Errorf("RangeQuery within %d of %d bits is expected %v but got %v", maxDistance, bits, expected, found)
+				}
+			}
+		}
+	}
+}
+
+func TestBKTreeErrors(t *testing.T) {
+	tree := NewBKTree()
+	hash := goimagehash.NewImageHash(0, goimagehash.AHash)
+	if found, err := tree.RangeQuery(hash, 10); err != nil || len(found) != 0 {
+		t.Errorf("RangeQuery of an empty tree is expected nothing but got %v, %v", found, err)
+	}
+	if err := tree.Add(1, nil); err == nil {
+		t.Errorf("Add of a nil hash is expected to fail")
+	}
+	if err := tree.Add(1, hash); err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, other := range []goimagehash.Hash{
+		goimagehash.NewImageHash(1, goimagehash.DHash),
+		goimagehash.NewExtImageHash([]uint64{1}, goimagehash.AHash, 64),
+	} {
+		if err := tree.Add(2, other); err == nil {
+			t.Errorf("Add of %v to a tree of %v is expected to fail", other.ToString(), hash.ToString())
+		}
+		if _, err := tree.RangeQuery(other, 10); err == nil {
+			t.Errorf("RangeQuery of %v in a tree of %v is expected to fail", other.ToString(), hash.ToString())
+		}
+	}
+	if _, err := tree.RangeQuery(nil, 10); err == nil {
+		t.Errorf("RangeQuery of a nil hash is expected to fail")
+	}
+	if tree.Len() != 1 {
+		t.Errorf("failed additions are expected not to be counted")
+	}
+}
+
+func BenchmarkBKTreeRangeQuery(b *testing.B) {
+	items := randomItems(100000, 64, 1)
+	tree := NewBKTree()
+	for _, item := range items {
+		tree.Add(item.ID, item.Hash)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.RangeQuery(items[i%len(items)].Hash, 6)
+	}
+}
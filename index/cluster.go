@@ -0,0 +1,227 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"container/heap"
+)
+
+// Group is a cluster of items.
+type Group struct {
+	// Representative is the item with the most neighbours in the group, then
+	// the smallest sum of distances to them, then the first one.
+	Representative Item
+	// Items holds the items of the group in their order in the clustered ones.
+	Items []Item
+}
+
+// ClusterOption is a option of Cluster.
+type ClusterOption func(*clusterOptions)
+
+type clusterOptions struct {
+	complete bool
+}
+
+// CompleteLinkage function returns a ClusterOption grouping items only when
+// each one is at most maxDistance away from all the others, instead of
+// chaining the neighbours of neighbours. Groups are merged closest first.
+func CompleteLinkage() ClusterOption {
+	return func(o *clusterOptions) {
+		o.complete = true
+	}
+}
+
+// neighbour is an item at some distance from another one.
+type neighbour struct {
+	index, distance int
+}
+
+// Cluster function groups the items connected through neighbours at most
+// maxDistance away from each other. Each item is in one group, alone if it
+// has no neighbour. Groups are in the order of their first items.
+// Neighbours are found by the range queries of a MIH.
+func Cluster(items []Item, maxDistance int, opts ...ClusterOption) ([]Group, error) {
+	var o clusterOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	m := NewMIH(0)
+	for i, item := range items {
+		if err := m.Add(uint64(i), item.Hash); err != nil {
+			return nil, err
+		}
+	}
+	neighbours := make([][]neighbour, len(items))
+	for i, item := range items {
+		found, err := m.RangeQuery(item.Hash, maxDistance)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range found {
+			if j := int(match.ID); j != i {
+				neighbours[i] = append(neighbours[i], neighbour{j, match.Distance})
+			}
+		}
+	}
+
+	var groups unionFind
+	if o.complete {
+		groups = completeLinkage(neighbours)
+	} else {
+		groups = newUnionFind(len(items))
+		for i, n := range neighbours {
+			for _, n := range n {
+				groups.union(i, n.index)
+			}
+		}
+	}
+
+	var clusters []Group
+	cluster := make(map[int]int)
+	for i, item := range items {
+		root := groups.find(i)
+		c, ok := cluster[root]
+		if !ok {
+			c = len(clusters)
+			cluster[root] = c
+			clusters = append(clusters, Group{Representative: item})
+		}
+		clusters[c].Items = append(clusters[c].Items, item)
+	}
+	// best holds the neighbours in the group of the representatives and the
+	// sum of distances to them.
+	best := make([][2]int, len(clusters))
+	for i := range best {
+		best[i][0] = -1
+	}
+	for i, item := range items {
+		root := groups.find(i)
+		count, sum := 0, 0
+		for _, n := range neighbours[i] {
+			if groups.find(n.index) == root {
+				count, sum = count+1, sum+n.distance
+			}
+		}
+		c := cluster[root]
+		if count > best[c][0] || (count == best[c][0] && sum < best[c][1]) {
+			best[c] = [2]int{count, sum}
+			clusters[c].Representative = item
+		}
+	}
+	return clusters, nil
+}
+
+// unionFind holds the parent of each item, or itself for the roots of groups.
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	u := make(unionFind, n)
+	for i := range u {
+		u[i] = i
+	}
+	return u
+}
+
+// find returns the root of the group of i, halving the path to it.
+func (u unionFind) find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+// union merges the groups of i and j under the smallest root.
+func (u unionFind) union(i, j int) int {
+	i, j = u.find(i), u.find(j)
+	if j < i {
+		i, j = j, i
+	}
+	u[j] = i
+	return i
+}
+
+// linkage is the distance between two groups, the largest one between their
+// items, when their versions are the current ones.
+type linkage struct {
+	distance           int
+	a, b               int
+	aVersion, bVersion int
+}
+
+// linkages is a heap of linkages, the closest then smallest roots first.
+type linkages []linkage
+
+func (l linkages) Len() int { return len(l) }
+func (l linkages) Less(i, j int) bool {
+	if l[i].distance != l[j].distance {
+		return l[i].distance < l[j].distance
+	}
+	if l[i].a != l[j].a {
+		return l[i].a < l[j].a
+	}
+	return l[i].b < l[j].b
+}
+func (l linkages) Swap(i, j int)       { l[i], l[j] = l[j], l[i] }
+func (l *linkages) Push(x interface{}) { *l = append(*l, x.(linkage)) }
+func (l *linkages) Pop() interface{} {
+	x := (*l)[len(*l)-1]
+	*l = (*l)[:len(*l)-1]
+	return x
+}
+
+// completeLinkage function returns the groups of items merged closest first
+// while all their items are neighbours. The linkages to a merged group are
+// the largest of the ones to its halves, and exist only when both do.
+func completeLinkage(neighbours [][]neighbour) unionFind {
+	groups := newUnionFind(len(neighbours))
+	versions := make([]int, len(neighbours))
+	links := make([]map[int]int, len(neighbours))
+	var queue linkages
+	for i, n := range neighbours {
+		links[i] = make(map[int]int, len(n))
+		for _, n := range n {
+			links[i][n.index] = n.distance
+			if i < n.index {
+				queue = append(queue, linkage{n.distance, i, n.index, 0, 0})
+			}
+		}
+	}
+	heap.Init(&queue)
+	for queue.Len() > 0 {
+		l := heap.Pop(&queue).(linkage)
+		if versions[l.a] != l.aVersion || versions[l.b] != l.bVersion || groups[l.a] != l.a || groups[l.b] != l.b {
+			continue
+		}
+		root := groups.union(l.a, l.b)
+		other := l.a + l.b - root
+		merged := make(map[int]int)
+		for k, d := range links[root] {
+			if e, ok := links[other][k]; ok && k != other {
+				if e > d {
+					d = e
+				}
+				merged[k] = d
+			}
+		}
+		for k := range links[root] {
+			delete(links[k], root)
+		}
+		for k := range links[other] {
+			delete(links[k], other)
+		}
+		links[root], links[other] = merged, nil
+		versions[root]++
+		for k, d := range merged {
+			links[k][root] = d
+			a, b := root, k
+			if b < a {
+				a, b = b, a
+			}
+			heap.Push(&queue, linkage{d, a, b, versions[a], versions[b]})
+		}
+	}
+	return groups
+}
@@ -0,0 +1,188 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/corona10/goimagehash"
+)
+
+// groupIDs returns the IDs of the items of each group, then of the
+// representatives.
+func groupIDs(groups []Group) ([][]uint64, []uint64) {
+	var ids [][]uint64
+	var representatives []uint64
+	for _, g := range groups {
+		var group []uint64
+		for _, item := range g.Items {
+			group = append(group, item.ID)
+		}
+		ids = append(ids, group)
+		representatives = append(representatives, g.Representative.ID)
+	}
+	return ids, representatives
+}
+
+func TestCluster(t *testing.T) {
+	hash := func(h uint64) goimagehash.Hash { return goimagehash.NewImageHash(h, goimagehash.AHash) }
+	items := []Item{
+		{1, hash(0x0)},
+		{2, hash(0xff00)},
+		{3, hash(0x3)},
+		{4, hash(0xf)},
+		{5, hash(0xfb00)},
+		{6, hash(0x7)},
+	}
+	for _, tt := range []struct {
+		opts            []ClusterOption
+		groups          [][]uint64
+		representatives []uint64
+	}{
+		// 1 is a neighbour of 3 only, which chains it to 4 and 6.
+		{nil, [][]uint64{{1, 3, 4, 6}, {2, 5}}, []uint64{3, 2}},
+		// 6 is the closest to the others of its group.
+		{[]ClusterOption{CompleteLinkage()}, [][]uint64{{1}, {2, 5}, {3, 4, 6}}, []uint64{1, 2, 6}},
+	} {
+		groups, err := Cluster(items, 2, tt.opts...)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		ids, representatives := groupIDs(groups)
+		if !reflect.DeepEqual(ids, tt.groups) || !reflect.DeepEqual(representatives, tt.representatives) {
+			t.Errorf("Cluster is expected %v represented by %v but got %v represented by %v", tt.groups, tt.representatives, ids, representatives)
+		}
+	}
+
+	if groups, err := Cluster(nil, 3); err != nil || len(groups) != 0 {
+		t.Errorf("Cluster of no items is expected no groups but got %v, %v", groups, err)
+	}
+	if _, err := Cluster([]Item{{1, hash(0)}, {2, goimagehash.NewImageHash(0, goimagehash.DHash)}}, 3); err == nil {
+		t.Errorf("Cluster of hashes of different kinds is expected to fail")
+	}
+}
+
+// checkGroups checks that groups hold each item once and picks their
+// representatives as documented, returning the group of each item.
+func checkGroups(t *testing.T, items []Item, groups []Group, maxDistance int) []int {
+	group := make([]int, len(items))
+	for i := range group {
+		group[i] = -1
+	}
+	byID := map[uint64]int{}
+	for i, item := range items {
+		byID[item.ID] = i
+	}
+	next := 0
+	for g, gr := range groups {
+		best := [2]int{-1, 0}
+		var representative uint64
+		for _, item := range gr.Items {
+			i := byID[item.ID]
+			if group[i] >= 0 {
+				t.Fatalf("item %d is in groups %d and %d", item.ID, group[i], g)
+			}
+			group[i] = g
+		}
+		for _, item := range gr.Items {
+			count, sum := 0, 0
+			for _, other := range gr.Items {
+				if d, _ := distance(item.Hash, other.Hash); other.ID != item.ID && d <= maxDistance {
+					count, sum = count+1, sum+d
+				}
+			}
+			if count > best[0] || (count == best[0] && sum < best[1]) {
+				best, representative = [2]int{count, sum}, item.ID
+			}
+		}
+		if gr.Representative.ID != representative {
+			t.Errorf("group %d is expected to be represented by %d but got %d", g, representative, gr.Representative.ID)
+		}
+		if first := byID[gr.Items[0].ID]; first < next {
+			t.Errorf("group %d is expected after the groups of the items before %d", g, first)
+		} else {
+			next = first
+		}
+	}
+	for i, g := range group {
+		if g < 0 {
+			t.Fatalf("item %d is in no group", items[i].ID)
+		}
+	}
+	return group
+}
+
+func TestClusterProperties(t *testing.T) {
+	for _, bits := range []int{64, 256} {
+		items := randomItems(800, bits, int64(bits))
+		for _, maxDistance := range []int{0, bits / 32, bits / 8, bits / 4} {
+			groups, err := Cluster(items, maxDistance)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			group := checkGroups(t, items, groups, maxDistance)
+			// Neighbours are in the same group, whose items are connected.
+			for i := range items {
+				connected := map[int]bool{i: true}
+				for stack := []int{i}; len(stack) > 0; {
+					j := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					for k := range items {
+						if d, _ := distance(items[j].Hash, items[k].Hash); d <= maxDistance && !connected[k] {
+							connected[k] = true
+							stack = append(stack, k)
+						}
+					}
+				}
+				if len(connected) != len(groups[group[i]].Items) {
+					t.Fatalf("the group of %d items is expected to hold the %d items connected to item %d", len(groups[group[i]].Items), len(connected), i)
+				}
+				for k := range connected {
+					if group[k] != group[i] {
+						t.Fatalf("connected items %d and %d are expected in the same group", i, k)
+					}
+				}
+			}
+
+			complete, err := Cluster(items, maxDistance, CompleteLinkage())
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			group = checkGroups(t, items, complete, maxDistance)
+			// Items of a group are neighbours, and no groups could be merged.
+			mergeable := make(map[[2]int]bool)
+			for i := range items {
+				for j := range items {
+					d, _ := distance(items[i].Hash, items[j].Hash)
+					if group[i] == group[j] && d > maxDistance {
+						t.Fatalf("items %d and %d of the same group are %d apart", i, j, d)
+					}
+					key := [2]int{group[i], group[j]}
+					if _, ok := mergeable[key]; !ok {
+						mergeable[key] = true
+					}
+					mergeable[key] = mergeable[key] && d <= maxDistance
+				}
+			}
+			for key, ok := range mergeable {
+				if ok && key[0] != key[1] {
+					t.Errorf("groups %d and %d of %d bits within %d are expected to be merged", key[0], key[1], bits, maxDistance)
+				}
+			}
+		}
+	}
+}
+
+func benchmarkCluster(b *testing.B, opts ...ClusterOption) {
+	items := randomItems(100000, 64, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Cluster(items, 6, opts...)
+	}
+}
+
+func BenchmarkCluster(b *testing.B)                { benchmarkCluster(b) }
+func BenchmarkClusterCompleteLinkage(b *testing.B) { benchmarkCluster(b, CompleteLinkage()) }
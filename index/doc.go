@@ -0,0 +1,49 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package index finds the hashes close to a hash among many, without
+// comparing it with each of them.
+//
+// BKTree suits any distance and size of hashes. MIH is much faster for the
+// small distances of near duplicates, and also removes hashes. VPTree, built
+// at once, suits large hashes searched at large distances.
+//
+// # Index files
+//
+// CreateFile and AppendFile write the items of an index to a file, which
+// OpenFile queries through an io.ReaderAt. Integers are stored big endian and
+// checksums are IEEE CRC-32. A file starts with a header:
+//
+//	offset  size  content
+//	0       8     "GOIMGIDX"
+//	8       2     version, 1
+//	10      2     flags, 1 for ExtImageHash, 0 for ImageHash
+//	12      4     bits of the hashes
+//	16      4     substrings of the MIH tables, 0 without tables
+//	20      2     length n of the kind name
+//	22      4     reserved, 0
+//	26      n     kind name, as registered with goimagehash.RegisterKind
+//	26+n    4     checksum of the header
+//
+// Segments of items, each followed by a footer, are then appended. A segment
+// of count items holds their records, then with MIH tables one table per
+// substring. A record is the ID of an item on 8 bytes, then the words of its
+// hash on 8 bytes each, the first bit of the hash being the most significant
+// one of the first word. The entries of a table are the substring of an item,
+// whose first bit is the most significant one, on 4 bytes, then the index of
+// the item in the segment on 4 bytes, sorted. A footer is:
+//
+//	offset  size  content
+//	0       8     "GOIMGEND"
+//	8       8     offset of the footer in the file
+//	16      8     offset of the previous footer, 0 for the first one
+//	24      8     number of items up to the segment
+//	32      8     count of items of the segment
+//	40      4     checksum of the segment
+//	44      4     checksum of the footer
+//
+// A segment is synced before its footer is written, and counts once its
+// footer is. After a crash, the bytes following the last valid footer are
+// ignored, then truncated by AppendFile.
+package index
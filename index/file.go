@@ -0,0 +1,645 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+
+	"github.com/corona10/goimagehash"
+)
+
+// File is an index file open for reading and writing, like a *os.File.
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	Truncate(size int64) error
+	Sync() error
+}
+
+const (
+	fileMagic   = "GOIMGIDX"
+	footerMagic = "GOIMGEND"
+	fileVersion = 1
+	// fileExt flags the files of ExtImageHash.
+	fileExt = 1
+	// headerSize is the size of a header without the kind name.
+	headerSize = 26
+	footerSize = 48
+	// entrySize is the size of an entry of the MIH tables.
+	entrySize = 8
+)
+
+var errCorruptFile = errors.New("corrupt index file")
+
+// fileHeader describes the hashes of an index file.
+type fileHeader struct {
+	ext        bool
+	kind       goimagehash.Kind
+	bits       int
+	substrings int
+	// size is the size of the header in the file.
+	size int64
+}
+
+// newFileHeader function returns the header of a file of hashes like hash.
+func newFileHeader(hash goimagehash.Hash, tables bool) (*fileHeader, error) {
+	_, bits, err := hashWords(hash)
+	if err != nil {
+		return nil, err
+	}
+	h := &fileHeader{bits: bits}
+	switch hash := hash.(type) {
+	case *goimagehash.ImageHash:
+		h.kind = hash.GetKind()
+	case *goimagehash.ExtImageHash:
+		h.ext, h.kind = true, hash.GetKind()
+	}
+	if k, err := goimagehash.ParseKind(h.kind.String()); err != nil || k != h.kind {
+		return nil, fmt.Errorf("kind %v is not registered", h.kind)
+	}
+	if tables {
+		split, err := newSplit(bits, 0)
+		if err != nil {
+			return nil, err
+		}
+		h.substrings = split.count()
+	}
+	h.size = int64(headerSize + len(h.kind.String()) + 4)
+	return h, nil
+}
+
+// marshal returns the header as stored in a file.
+func (h *fileHeader) marshal() []byte {
+	name := h.kind.String()
+	b := make([]byte, headerSize+len(name), h.size)
+	copy(b, fileMagic)
+	binary.BigEndian.PutUint16(b[8:], fileVersion)
+	if h.ext {
+		binary.BigEndian.PutUint16(b[10:], fileExt)
+	}
+	binary.BigEndian.PutUint32(b[12:], uint32(h.bits))
+	binary.BigEndian.PutUint32(b[16:], uint32(h.substrings))
+	binary.BigEndian.PutUint16(b[20:], uint16(len(name)))
+	copy(b[headerSize:], name)
+	return append(b, crc32Bytes(b)...)
+}
+
+// readHeader function reads the header of a file of size bytes.
+func readHeader(r io.ReaderAt, size int64) (*fileHeader, error) {
+	b := make([]byte, headerSize)
+	if size < headerSize {
+		return nil, errCorruptFile
+	}
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return nil, err
+	}
+	if string(b[:8]) != fileMagic {
+		return nil, fmt.Errorf("%s: no header", errCorruptFile)
+	}
+	if v := binary.BigEndian.Uint16(b[8:]); v != fileVersion {
+		return nil, fmt.Errorf("index file version %d is not supported", v)
+	}
+	h := &fileHeader{
+		ext:        binary.BigEndian.Uint16(b[10:])&fileExt != 0,
+		bits:       int(binary.BigEndian.Uint32(b[12:])),
+		substrings: int(binary.BigEndian.Uint32(b[16:])),
+	}
+	h.size = int64(headerSize + int(binary.BigEndian.Uint16(b[20:])) + 4)
+	if h.size > size {
+		return nil, errCorruptFile
+	}
+	b = append(b, make([]byte, h.size-headerSize)...)
+	if _, err := r.ReadAt(b[headerSize:], headerSize); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(crc32Bytes(b[:h.size-4]), b[h.size-4:]) {
+		return nil, fmt.Errorf("%s: header checksum mismatch", errCorruptFile)
+	}
+	if h.bits <= 0 || (!h.ext && h.bits != 64) {
+		return nil, fmt.Errorf("%s: %d bits hashes", errCorruptFile, h.bits)
+	}
+	if h.substrings > 0 {
+		if _, err := newSplit(h.bits, h.substrings); err != nil {
+			return nil, err
+		}
+	}
+	kind, err := goimagehash.ParseKind(string(b[headerSize : h.size-4]))
+	if err != nil {
+		return nil, err
+	}
+	h.kind = kind
+	return h, nil
+}
+
+// words returns the number of words of a hash.
+func (h *fileHeader) words() int {
+	return (h.bits + 63) / 64
+}
+
+// recordSize returns the size of the record of an item.
+func (h *fileHeader) recordSize() int64 {
+	return int64(8 + 8*h.words())
+}
+
+// segmentSize returns the size of a segment of count items.
+func (h *fileHeader) segmentSize(count uint64) int64 {
+	return int64(count) * (h.recordSize() + int64(h.substrings)*entrySize)
+}
+
+// hash returns the hash of words.
+func (h *fileHeader) hash(words []uint64) goimagehash.Hash {
+	if h.ext {
+		return goimagehash.NewExtImageHash(words, h.kind, h.bits)
+	}
+	return goimagehash.NewImageHash(words[0], h.kind)
+}
+
+// check returns the bits of hash unless it can not be compared with the
+// hashes of the file.
+func (h *fileHeader) check(hash goimagehash.Hash) ([]uint64, error) {
+	words, _, err := hashWords(hash)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := distance(h.hash(make([]uint64, h.words())), hash); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// footer commits the segment of items before it.
+type footer struct {
+	// offset is the offset of the footer and prev the one of the previous
+	// footer, or 0 for the first one.
+	offset, prev int64
+	// total counts the items up to the segment, count the ones of the segment.
+	total, count uint64
+	// crc is the checksum of the segment.
+	crc uint32
+}
+
+// marshal returns the footer as stored in a file.
+func (f footer) marshal() []byte {
+	b := make([]byte, footerSize-4, footerSize)
+	copy(b, footerMagic)
+	binary.BigEndian.PutUint64(b[8:], uint64(f.offset))
+	binary.BigEndian.PutUint64(b[16:], uint64(f.prev))
+	binary.BigEndian.PutUint64(b[24:], f.total)
+	binary.BigEndian.PutUint64(b[32:], f.count)
+	binary.BigEndian.PutUint32(b[40:], f.crc)
+	return append(b, crc32Bytes(b)...)
+}
+
+// start returns the offset of the segment of the footer.
+func (f footer) start(h *fileHeader) int64 {
+	if f.prev == 0 {
+		return h.size
+	}
+	return f.prev + footerSize
+}
+
+// unmarshalFooter function returns the footer stored in b at offset, and
+// whether it is a footer of a segment of items of h.
+func unmarshalFooter(b []byte, offset int64, h *fileHeader) (footer, bool) {
+	if len(b) < footerSize || string(b[:8]) != footerMagic || !bytes.Equal(crc32Bytes(b[:footerSize-4]), b[footerSize-4:footerSize]) {
+		return footer{}, false
+	}
+	f := footer{
+		offset: int64(binary.BigEndian.Uint64(b[8:])),
+		prev:   int64(binary.BigEndian.Uint64(b[16:])),
+		total:  binary.BigEndian.Uint64(b[24:]),
+		count:  binary.BigEndian.Uint64(b[32:]),
+		crc:    binary.BigEndian.Uint32(b[40:]),
+	}
+	if f.offset != offset || f.prev < 0 || f.prev >= offset || f.count == 0 || f.count > f.total || f.count > uint64(offset/h.recordSize()) {
+		return footer{}, false
+	}
+	return f, f.start(h)+h.segmentSize(f.count) == offset
+}
+
+// lastFooter function returns the last footer of a file of size bytes, and
+// whether there is any. After a crash, bytes torn from an unfinished segment
+// may follow it, which are searched back for it.
+func lastFooter(r io.ReaderAt, h *fileHeader, size int64) (footer, bool, error) {
+	const window = 64 << 10
+	buf := make([]byte, window+footerSize)
+	for end := size; end-h.size >= footerSize; end -= window {
+		start := end - window - footerSize
+		if start < h.size {
+			start = h.size
+		}
+		b := buf[:end-start]
+		if _, err := r.ReadAt(b, start); err != nil && err != io.EOF {
+			return footer{}, false, err
+		}
+		for i := bytes.LastIndex(b, []byte(footerMagic)); i >= 0; i = bytes.LastIndex(b[:i], []byte(footerMagic)) {
+			if f, ok := unmarshalFooter(b[i:], start+int64(i), h); ok {
+				return f, true, nil
+			}
+		}
+	}
+	return footer{}, false, nil
+}
+
+// crc32Bytes function returns the big endian IEEE checksum of b.
+func crc32Bytes(b []byte) []byte {
+	c := make([]byte, 4)
+	binary.BigEndian.PutUint32(c, crc32.ChecksumIEEE(b))
+	return c
+}
+
+// FileWriter appends items to an index file. Items are written in
+// segments, which are visible once committed.
+type FileWriter struct {
+	f      File
+	header *fileHeader
+	split  split
+	// last is the last footer, whose offset is 0 until one is written.
+	last footer
+	// end is the size of the committed file.
+	end int64
+	// records and words hold the items added since the last commit.
+	records []byte
+	words   []uint64
+}
+
+// CreateFile function truncates f and writes the header of an index of
+// hashes of the type, kind and size of hash, with MIH tables or not.
+func CreateFile(f File, hash goimagehash.Hash, tables bool) (*FileWriter, error) {
+	h, err := newFileHeader(hash, tables)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(0); err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteAt(h.marshal(), 0); err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	return newFileWriter(f, h, footer{}, h.size)
+}
+
+// AppendFile function returns a FileWriter appending to the index file f of
+// size bytes. It truncates any segment a crash left unfinished.
+func AppendFile(f File, size int64) (*FileWriter, error) {
+	h, err := readHeader(f, size)
+	if err != nil {
+		return nil, err
+	}
+	last, ok, err := lastFooter(f, h, size)
+	if err != nil {
+		return nil, err
+	}
+	end := h.size
+	if ok {
+		end = last.offset + footerSize
+	}
+	if end < size {
+		if err := f.Truncate(end); err != nil {
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			return nil, err
+		}
+	}
+	return newFileWriter(f, h, last, end)
+}
+
+func newFileWriter(f File, h *fileHeader, last footer, end int64) (*FileWriter, error) {
+	w := &FileWriter{f: f, header: h, last: last, end: end}
+	if h.substrings > 0 {
+		split, err := newSplit(h.bits, h.substrings)
+		if err != nil {
+			return nil, err
+		}
+		w.split = split
+	}
+	return w, nil
+}
+
+// Len method returns the number of committed items.
+func (w *FileWriter) Len() int {
+	return int(w.last.total)
+}
+
+// Add method adds the hash of the image id to the next segment.
+func (w *FileWriter) Add(id uint64, hash goimagehash.Hash) error {
+	words, err := w.header.check(hash)
+	if err != nil {
+		return err
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	w.records = append(w.records, b[:]...)
+	for _, word := range words {
+		binary.BigEndian.PutUint64(b[:], word)
+		w.records = append(w.records, b[:]...)
+	}
+	w.words = append(w.words, words...)
+	return nil
+}
+
+// uint64s sorts uint64 values.
+type uint64s []uint64
+
+func (u uint64s) Len() int           { return len(u) }
+func (u uint64s) Less(i, j int) bool { return u[i] < u[j] }
+func (u uint64s) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+
+// Commit method writes the items added since the last commit as a segment.
+// The segment is synced before its footer, which commits it once synced.
+func (w *FileWriter) Commit() error {
+	width := w.header.words()
+	count := len(w.words) / width
+	if count == 0 {
+		return nil
+	}
+	segment := w.records
+	if w.split != nil {
+		// Each table holds the substring << 32 | index of the items,
+		// sorted.
+		entries := make([]uint64, count)
+		var b [entrySize]byte
+		for i := 0; i < w.split.count(); i++ {
+			for j := range entries {
+				entries[j] = w.split.substring(w.words[j*width:(j+1)*width], i)<<32 | uint64(j)
+			}
+			sort.Sort(uint64s(entries))
+			for _, entry := range entries {
+				binary.BigEndian.PutUint64(b[:], entry)
+				segment = append(segment, b[:]...)
+			}
+		}
+	}
+	f := footer{
+		offset: w.end + int64(len(segment)),
+		prev:   w.last.offset,
+		total:  w.last.total + uint64(count),
+		count:  uint64(count),
+		crc:    crc32.ChecksumIEEE(segment),
+	}
+	if _, err := w.f.WriteAt(segment, w.end); err != nil {
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	if _, err := w.f.WriteAt(f.marshal(), f.offset); err != nil {
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	w.last, w.end = f, f.offset+footerSize
+	w.records, w.words = w.records[:0], w.words[:0]
+	return nil
+}
+
+// FileIndex queries an index file without reading it in memory.
+// Its segments are scanned, or probed through their MIH tables when they
+// have some and the queried distance is small.
+type FileIndex struct {
+	r        io.ReaderAt
+	header   *fileHeader
+	split    split
+	segments []fileSegment
+	size     int
+}
+
+// fileSegment locates a committed segment.
+type fileSegment struct {
+	offset int64
+	count  int
+	crc    uint32
+}
+
+// OpenFile function returns the FileIndex of the items committed to the index
+// file r of size bytes.
+func OpenFile(r io.ReaderAt, size int64) (*FileIndex, error) {
+	h, err := readHeader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	x := &FileIndex{r: r, header: h}
+	if h.substrings > 0 {
+		if x.split, err = newSplit(h.bits, h.substrings); err != nil {
+			return nil, err
+		}
+	}
+	f, ok, err := lastFooter(r, h, size)
+	if err != nil || !ok {
+		return x, err
+	}
+	x.size = int(f.total)
+	b := make([]byte, footerSize)
+	for {
+		x.segments = append(x.segments, fileSegment{f.start(h), int(f.count), f.crc})
+		if f.prev == 0 {
+			break
+		}
+		prev := f
+		if _, err := r.ReadAt(b, prev.prev); err != nil {
+			return nil, err
+		}
+		if f, ok = unmarshalFooter(b, prev.prev, h); !ok || f.total != prev.total-prev.count {
+			return nil, fmt.Errorf("%s: broken footer at %d", errCorruptFile, prev.prev)
+		}
+	}
+	if f.total != f.count {
+		return nil, fmt.Errorf("%s: missing segments", errCorruptFile)
+	}
+	for i, j := 0, len(x.segments)-1; i < j; i, j = i+1, j-1 {
+		x.segments[i], x.segments[j] = x.segments[j], x.segments[i]
+	}
+	return x, nil
+}
+
+// Len method returns the number of items in the file.
+func (x *FileIndex) Len() int {
+	return x.size
+}
+
+// Verify method checks the checksums of all the segments, reading them once.
+func (x *FileIndex) Verify() error {
+	buf := make([]byte, 64<<10)
+	for _, s := range x.segments {
+		crc := crc32.NewIEEE()
+		for offset, end := s.offset, s.offset+x.header.segmentSize(uint64(s.count)); offset < end; {
+			b := buf
+			if int64(len(b)) > end-offset {
+				b = b[:end-offset]
+			}
+			if _, err := x.r.ReadAt(b, offset); err != nil {
+				return err
+			}
+			crc.Write(b)
+			offset += int64(len(b))
+		}
+		if crc.Sum32() != s.crc {
+			return fmt.Errorf("%s: checksum mismatch of the segment at %d", errCorruptFile, s.offset)
+		}
+	}
+	return nil
+}
+
+// scan calls fn with the index in the segment, ID and bits of each item of s.
+// words is reused between calls.
+func (x *FileIndex) scan(s fileSegment, fn func(i int, id uint64, words []uint64) error) error {
+	size := x.header.recordSize()
+	chunk := int(64<<10/size) + 1
+	buf := make([]byte, int64(chunk)*size)
+	words := make([]uint64, x.header.words())
+	for first := 0; first < s.count; first += chunk {
+		b := buf
+		if n := s.count - first; n < chunk {
+			b = b[:int64(n)*size]
+		}
+		if _, err := x.r.ReadAt(b, s.offset+int64(first)*size); err != nil {
+			return err
+		}
+		for i := 0; len(b) > 0; i++ {
+			for j := range words {
+				words[j] = binary.BigEndian.Uint64(b[8+8*j:])
+			}
+			if err := fn(first+i, binary.BigEndian.Uint64(b), words); err != nil {
+				return err
+			}
+			b = b[size:]
+		}
+	}
+	return nil
+}
+
+// Items method calls fn with each item of the file in the order they were
+// added, until it returns an error.
+func (x *FileIndex) Items(fn func(item Item) error) error {
+	for _, s := range x.segments {
+		err := x.scan(s, func(i int, id uint64, words []uint64) error {
+			return fn(Item{id, x.header.hash(append([]uint64{}, words...))})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RangeQuery method returns the items at most maxDistance away from hash,
+// sorted by distance then by ID.
+func (x *FileIndex) RangeQuery(hash goimagehash.Hash, maxDistance int) ([]Match, error) {
+	query, err := x.header.check(hash)
+	if err != nil {
+		return nil, err
+	}
+	if maxDistance > x.header.bits {
+		maxDistance = x.header.bits
+	}
+	var found []Match
+	var out [1]int
+	match := func(i int, id uint64, words []uint64) error {
+		goimagehash.ExtDistances(query, words, out[:])
+		if out[0] <= maxDistance {
+			found = append(found, Match{Item{id, x.header.hash(append([]uint64{}, words...))}, out[0]})
+		}
+		return nil
+	}
+	for _, s := range x.segments {
+		if maxDistance < 0 {
+			break
+		}
+		// Each probe binary searches a table, which costs about as much
+		// as scanning log2(count) items.
+		limit := s.count
+		for n := s.count; n > 1; n >>= 1 {
+			limit--
+		}
+		if x.split == nil || x.probes(maxDistance, limit) > limit {
+			err = x.scan(s, match)
+		} else {
+			err = x.probe(s, query, maxDistance, match)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sortMatches(found), nil
+}
+
+// probes returns the number of probes of the tables for maxDistance, or more
+// than limit.
+func (x *FileIndex) probes(maxDistance, limit int) int {
+	probes := 0
+	for i := 0; i < x.split.count(); i++ {
+		for r := 0; r <= x.split.radius(i, maxDistance) && probes <= limit; r++ {
+			probes += x.split.probes(i, r, limit)
+		}
+	}
+	return probes
+}
+
+// probe calls fn with the items of s whose substrings are close enough to
+// the ones of query to be at most maxDistance away from it, each once.
+func (x *FileIndex) probe(s fileSegment, query []uint64, maxDistance int, fn func(i int, id uint64, words []uint64) error) error {
+	size := x.header.recordSize()
+	tables := s.offset + int64(s.count)*size
+	seen := map[int]bool{}
+	record := make([]byte, size)
+	words := make([]uint64, x.header.words())
+	entry := make([]byte, entrySize)
+	read := func(table int64, j int) (uint64, error) {
+		_, err := x.r.ReadAt(entry, table+int64(j)*entrySize)
+		return binary.BigEndian.Uint64(entry), err
+	}
+	for i := 0; i < x.split.count(); i++ {
+		table := tables + int64(i*s.count)*entrySize
+		key := x.split.substring(query, i)
+		for r := 0; r <= x.split.radius(i, maxDistance); r++ {
+			err := x.split.masks(i, r, func(mask uint64) error {
+				first := (key ^ mask) << 32
+				var err error
+				j := sort.Search(s.count, func(j int) bool {
+					v, e := read(table, j)
+					if e != nil && err == nil {
+						err = e
+					}
+					return v >= first
+				})
+				for ; j < s.count && err == nil; j++ {
+					v, e := read(table, j)
+					if e != nil || v>>32 != first>>32 {
+						err = e
+						break
+					}
+					index := int(uint32(v))
+					if seen[index] || index >= s.count {
+						continue
+					}
+					seen[index] = true
+					if _, err = x.r.ReadAt(record, s.offset+int64(index)*size); err != nil {
+						break
+					}
+					for k := range words {
+						words[k] = binary.BigEndian.Uint64(record[8+8*k:])
+					}
+					err = fn(index, binary.BigEndian.Uint64(record), words)
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/corona10/goimagehash"
+)
+
+// memFile is a File in memory, which counts its syncs.
+type memFile struct {
+	data  []byte
+	syncs int
+}
+
+func (f *memFile) ReadAt(b []byte, offset int64) (int, error) {
+	if offset >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[offset:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(b []byte, offset int64) (int, error) {
+	if end := int(offset) + len(b); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	return copy(f.data[offset:], b), nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.data = f.data[:size]
+	return nil
+}
+
+func (f *memFile) Sync() error {
+	f.syncs++
+	return nil
+}
+
+// writeFile returns a file of the items committed in the given segments,
+// with the sizes of the file after each commit.
+func writeFile(t *testing.T, items []Item, tables bool, segments ...int) (*memFile, []int) {
+	f := &memFile{}
+	w, err := CreateFile(f, items[0].Hash, tables)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	sizes := []int{len(f.data)}
+	for _, n := range segments {
+		for _, item := range items[:n] {
+			if err := w.Add(item.ID, item.Hash); err != nil {
+				t.Fatalf("%s", err)
+			}
+		}
+		items = items[n:]
+		if err := w.Commit(); err != nil {
+			t.Fatalf("%s", err)
+		}
+		sizes = append(sizes, len(f.data))
+	}
+	return f, sizes
+}
+
+// checkFile compares the queries of an index file with a brute force scan of
+// items.
+func checkFile(t *testing.T, data []byte, items, queries []Item, bits int) {
+	x, err := OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if x.Len() != len(items) {
+		t.Errorf("index file is expected to hold %d items but holds %d", len(items), x.Len())
+		return
+	}
+	if err := x.Verify(); err != nil {
+		t.Errorf("%s", err)
+	}
+	i := 0
+	err = x.Items(func(item Item) error {
+		if d, err := distance(item.Hash, items[i].Hash); err != nil || d != 0 || item.ID != items[i].ID {
+			t.Errorf("item %d is expected %v but got %v", i, items[i], item)
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+	for _, query := range queries {
+		for _, maxDistance := range []int{-1, 0, 3, bits / 8, bits / 2, bits + 1} {
+			found, err := x.RangeQuery(query.Hash, maxDistance)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if expected := bruteForce(t, items, query.Hash, maxDistance); !sameMatches(found, expected) {
+				t.Errorf("RangeQuery within %d of %d bits is expected %d items but got %d", maxDistance, bits, len(expected), len(found))
+			}
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	for _, bits := range []int{64, 72, 256} {
+		items := randomItems(3000, bits, int64(bits))
+		queries := append(randomItems(5, bits, int64(bits)+1), items[5], items[2500])
+		for _, tables := range []bool{false, true} {
+			f, sizes := writeFile(t, items, tables, 1000, 1, 1999)
+			if f.syncs != 7 {
+				t.Errorf("3 commits are expected 7 syncs but got %d", f.syncs)
+			}
+			checkFile(t, f.data, items, queries, bits)
+
+			// A crash while committing the last segment loses it only.
+			for _, size := range []int{sizes[3] - 1, sizes[3] - footerSize, sizes[2] + 100, sizes[2] + 1} {
+				torn := append([]byte{}, f.data[:size]...)
+				checkFile(t, torn, items[:1001], queries, bits)
+
+				torn = append(torn, 'G', 'O')
+				file := &memFile{data: torn}
+				w, err := AppendFile(file, int64(len(torn)))
+				if err != nil {
+					t.Fatalf("%s", err)
+				}
+				if len(file.data) != sizes[2] || w.Len() != 1001 {
+					t.Errorf("AppendFile is expected to truncate %d bytes to %d but got %d", len(torn), sizes[2], len(file.data))
+				}
+				for _, item := range items[1001:] {
+					w.Add(item.ID, item.Hash)
+				}
+				if err := w.Commit(); err != nil {
+					t.Fatalf("%s", err)
+				}
+				if !bytes.Equal(file.data, f.data) {
+					t.Errorf("appending after a crash is expected to write the same file")
+				}
+			}
+
+			// Before the first commit, the file is empty.
+			checkFile(t, f.data[:sizes[1]-1], nil, queries, bits)
+			checkFile(t, f.data[:sizes[0]], nil, queries, bits)
+		}
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	hash := goimagehash.NewImageHash(0, goimagehash.PHash)
+	unregistered := goimagehash.NewImageHash(0, goimagehash.Kind(1000))
+	if _, err := CreateFile(&memFile{}, unregistered, false); err == nil {
+		t.Errorf("CreateFile of an unregistered kind is expected to fail")
+	}
+	if _, err := CreateFile(&memFile{}, nil, false); err == nil {
+		t.Errorf("CreateFile of a nil hash is expected to fail")
+	}
+
+	f := &memFile{}
+	w, err := CreateFile(f, hash, true)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, other := range []goimagehash.Hash{
+		nil,
+		goimagehash.NewImageHash(0, goimagehash.DHash),
+		goimagehash.NewExtImageHash([]uint64{0}, goimagehash.PHash, 64),
+	} {
+		if err := w.Add(1, other); err == nil {
+			t.Errorf("Add of %v to a file of %v is expected to fail", other, hash.ToString())
+		}
+	}
+	items := randomItems(100, 64, 1)
+	for _, item := range items {
+		if err := w.Add(item.ID, item.Hash); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if w.Len() != 0 {
+		t.Errorf("items are expected to be counted once committed")
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	x, err := OpenFile(bytes.NewReader(f.data), int64(len(f.data)))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := x.RangeQuery(goimagehash.NewImageHash(0, goimagehash.DHash), 3); err == nil {
+		t.Errorf("RangeQuery of a hash of another kind is expected to fail")
+	}
+
+	// The header and footers are checked, the segments by Verify.
+	header := int(w.header.size)
+	for _, tt := range []struct {
+		offset int
+		verify bool
+	}{
+		{0, false},
+		{9, false},
+		{header - 1, false},
+		{header, true},
+		{header + 100*16 + 5, true},
+	} {
+		data := append([]byte{}, f.data...)
+		data[tt.offset] ^= 1
+		x, err := OpenFile(bytes.NewReader(data), int64(len(data)))
+		if tt.verify && err == nil {
+			err = x.Verify()
+		}
+		if err == nil {
+			t.Errorf("a file with a corrupt byte at %d is expected to fail", tt.offset)
+		}
+	}
+	// A corrupt footer before the last one breaks the chain.
+	for _, item := range items {
+		w.Add(item.ID, item.Hash)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	data := append([]byte{}, f.data...)
+	data[int(w.last.prev)+30] ^= 1
+	if _, err := OpenFile(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Errorf("a file with a corrupt footer is expected to fail")
+	}
+	if _, err := OpenFile(bytes.NewReader(nil), 0); err == nil {
+		t.Errorf("an empty file is expected to fail")
+	}
+}
+
+func TestFileOS(t *testing.T) {
+	file, err := ioutil.TempFile("", "goimagehash")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	items := randomItems(500, 256, 1)
+	w, err := CreateFile(file, items[0].Hash, true)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, item := range items {
+		w.Add(item.ID, item.Hash)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	x, err := OpenFile(file, stat.Size())
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	found, err := x.RangeQuery(items[3].Hash, 10)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if expected := bruteForce(t, items, items[3].Hash, 10); !sameMatches(found, expected) {
+		t.Errorf("RangeQuery is expected %v but got %v", expected, found)
+	}
+}
+
+func benchmarkFile(b *testing.B, tables bool, maxDistance int) {
+	items := randomItems(100000, 64, 1)
+	f := &memFile{}
+	w, _ := CreateFile(f, items[0].Hash, tables)
+	for _, item := range items {
+		w.Add(item.ID, item.Hash)
+	}
+	w.Commit()
+	x, _ := OpenFile(bytes.NewReader(f.data), int64(len(f.data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.RangeQuery(items[i%len(items)].Hash, maxDistance)
+	}
+}
+
+func BenchmarkFileRangeQueryScan(b *testing.B)   { benchmarkFile(b, false, 6) }
+func BenchmarkFileRangeQueryTables(b *testing.B) { benchmarkFile(b, true, 6) }
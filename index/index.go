@@ -0,0 +1,90 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/corona10/goimagehash"
+)
+
+// Item is a hash with the ID of the image it was computed from.
+type Item struct {
+	ID   uint64
+	Hash goimagehash.Hash
+}
+
+// Match is an item found by a query with its distance to the queried hash.
+type Match struct {
+	Item
+	Distance int
+}
+
+// matches sorts matches by distance, then by ID.
+type matches []Match
+
+func (m matches) Len() int      { return len(m) }
+func (m matches) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m matches) Less(i, j int) bool {
+	if m[i].Distance != m[j].Distance {
+		return m[i].Distance < m[j].Distance
+	}
+	return m[i].ID < m[j].ID
+}
+
+// sortMatches sorts m by distance, then by ID, and returns it.
+func sortMatches(m []Match) []Match {
+	sort.Sort(matches(m))
+	return m
+}
+
+var errNilHash = errors.New("hash can not be nil")
+
+// distance returns the distance between two hashes of the same type with
+// their Distance method, which checks their kinds and sizes.
+func distance(a, b goimagehash.Hash) (int, error) {
+	switch a := a.(type) {
+	case *goimagehash.ImageHash:
+		if b, ok := b.(*goimagehash.ImageHash); ok && b != nil {
+			return a.Distance(b)
+		}
+	case *goimagehash.ExtImageHash:
+		if b, ok := b.(*goimagehash.ExtImageHash); ok && b != nil {
+			return a.Distance(b)
+		}
+	default:
+		return -1, fmt.Errorf("unsupported hash type %T", a)
+	}
+	if b == nil {
+		return -1, errNilHash
+	}
+	return -1, fmt.Errorf("hashes of types %T and %T can not be compared", a, b)
+}
+
+// hashWords returns the bits of a hash, the first one in the most significant
+// bit of the first word, with their number.
+func hashWords(hash goimagehash.Hash) ([]uint64, int, error) {
+	switch h := hash.(type) {
+	case *goimagehash.ImageHash:
+		if h != nil {
+			return []uint64{h.GetHash()}, h.Bits(), nil
+		}
+	case *goimagehash.ExtImageHash:
+		if h != nil {
+			words := h.GetHash()
+			if h.Bits() <= 0 || len(words)*64 < h.Bits() {
+				return nil, 0, fmt.Errorf("hash of %d bits holds %d words", h.Bits(), len(words))
+			}
+			return words, h.Bits(), nil
+		}
+	default:
+		if hash != nil {
+			return nil, 0, fmt.Errorf("unsupported hash type %T", hash)
+		}
+	}
+	return nil, 0, errNilHash
+}
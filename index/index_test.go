@@ -0,0 +1,97 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/corona10/goimagehash"
+)
+
+// randomItems returns n reproducible items of hashes of the given bits, made
+// of clusters of close hashes so that queries find a few items each.
+func randomItems(n, bits int, seed int64) []Item {
+	r := rand.New(rand.NewSource(seed))
+	words := (bits + 63) / 64
+	items := make([]Item, n)
+	var center []uint64
+	for i := range items {
+		if i%8 == 0 {
+			center = make([]uint64, words)
+			for j := range center {
+				center[j] = uint64(r.Int63())<<1 ^ uint64(r.Int63())
+			}
+			// The bits past the last one are zero like in computed hashes.
+			if bits%64 != 0 {
+				center[words-1] &^= 1<<uint(64-bits%64) - 1
+			}
+		}
+		hash := append([]uint64{}, center...)
+		for flips := r.Intn(bits / 8); flips > 0; flips-- {
+			b := r.Intn(bits)
+			hash[b/64] ^= 1 << uint(b%64)
+		}
+		if bits == 64 {
+			items[i] = Item{uint64(i) * 3, goimagehash.NewImageHash(hash[0], goimagehash.PHash)}
+		} else {
+			items[i] = Item{uint64(i) * 3, goimagehash.NewExtImageHash(hash, goimagehash.PHash, bits)}
+		}
+	}
+	return items
+}
+
+// bruteForce returns the items at most maxDistance away from hash by
+// comparing hash with each of them.
+func bruteForce(t *testing.T, items []Item, hash goimagehash.Hash, maxDistance int) []Match {
+	var found []Match
+	for _, item := range items {
+		d, err := distance(item.Hash, hash)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if d <= maxDistance {
+			found = append(found, Match{item, d})
+		}
+	}
+	return sortMatches(found)
+}
+
+// sameMatches reports whether two sorted lists find the same IDs at the same distances.
+func sameMatches(a, b []Match) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID || a[i].Distance != b[i].Distance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDistance(t *testing.T) {
+	a := goimagehash.NewImageHash(0xff, goimagehash.AHash)
+	b := goimagehash.NewImageHash(0x0f, goimagehash.AHash)
+	if d, err := distance(a, b); err != nil || d != 4 {
+		t.Errorf("distance is expected 4 but got %d, %v", d, err)
+	}
+	ext := goimagehash.NewExtImageHash([]uint64{0xff}, goimagehash.AHash, 64)
+	for _, tt := range [][2]goimagehash.Hash{
+		{a, ext},
+		{ext, a},
+		{a, goimagehash.NewImageHash(0, goimagehash.DHash)},
+		{a, nil},
+		{a, (*goimagehash.ImageHash)(nil)},
+	} {
+		if _, err := distance(tt[0], tt[1]); err == nil {
+			t.Errorf("distance(%v, %v) is expected to fail", tt[0], tt[1])
+		}
+	}
+	if m := sortMatches([]Match{{Item{3, a}, 2}, {Item{1, a}, 2}, {Item{2, a}, 1}}); !reflect.DeepEqual([]uint64{m[0].ID, m[1].ID, m[2].ID}, []uint64{2, 1, 3}) {
+		t.Errorf("matches are expected to be sorted by distance then ID but got %v", m)
+	}
+}
@@ -0,0 +1,451 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/corona10/goimagehash"
+)
+
+// maxSubstringBits is the size of the largest substring a MIH indexes.
+const maxSubstringBits = 32
+
+// MIH is a multi-index hashing index of hashes, after Norouzi, Punjani and
+// Fleet, "Fast Search in Hamming Space with Multi-Index Hashing", 2012.
+// It splits the hashes into substrings with a table of exact matches each:
+// two hashes at most r apart have a substring at most r / substrings apart,
+// so a query only probes the substrings close to its own ones.
+// The hashes of an index should all be of the same type, kind and size.
+// A MIH should not be modified while it is queried.
+type MIH struct {
+	substrings int
+	// sample is the first hash added, which the others are checked against.
+	sample goimagehash.Hash
+	bits   int
+	split  split
+	tables []map[uint64][]int
+	items  []mihItem
+	free   []int
+	ids    map[uint64][]int
+	size   int
+	// seen recycles the slots of searches.
+	seen sync.Pool
+}
+
+// mihItem is an item of a MIH with the bits of its hash.
+// Free slots have a nil Hash.
+type mihItem struct {
+	Item
+	words []uint64
+}
+
+// NewMIH function returns an empty MIH splitting hashes into the given number
+// of substrings, which should be at most 32 bits long. 0 or less chooses
+// 16 bits long substrings, which suits up to tens of millions of hashes.
+func NewMIH(substrings int) *MIH {
+	return &MIH{substrings: substrings, ids: map[uint64][]int{}}
+}
+
+// Len method returns the number of items in the index.
+func (m *MIH) Len() int {
+	return m.size
+}
+
+// Substrings method returns the number of substrings hashes are split into,
+// which is 0 until the first hash is added when it is chosen automatically.
+func (m *MIH) Substrings() int {
+	return len(m.tables)
+}
+
+// init splits the hashes into substrings after the size of the first one.
+func (m *MIH) init(hash goimagehash.Hash, bits int) error {
+	split, err := newSplit(bits, m.substrings)
+	if err != nil {
+		return err
+	}
+	m.sample, m.bits, m.split = hash, bits, split
+	m.tables = make([]map[uint64][]int, split.count())
+	for i := range m.tables {
+		m.tables[i] = map[uint64][]int{}
+	}
+	return nil
+}
+
+// check returns the bits of a hash of the type, kind and size of the index.
+func (m *MIH) check(hash goimagehash.Hash) ([]uint64, error) {
+	words, bits, err := hashWords(hash)
+	if err != nil {
+		return nil, err
+	}
+	if m.sample == nil {
+		if err := m.init(hash, bits); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := distance(m.sample, hash); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// Add method inserts the hash of the image id.
+func (m *MIH) Add(id uint64, hash goimagehash.Hash) error {
+	words, err := m.check(hash)
+	if err != nil {
+		return err
+	}
+	item := mihItem{Item{id, hash}, words}
+	var slot int
+	if n := len(m.free); n > 0 {
+		slot = m.free[n-1]
+		m.free = m.free[:n-1]
+		m.items[slot] = item
+	} else {
+		slot = len(m.items)
+		m.items = append(m.items, item)
+	}
+	for i, table := range m.tables {
+		key := m.split.substring(words, i)
+		table[key] = append(table[key], slot)
+	}
+	m.ids[id] = append(m.ids[id], slot)
+	m.size++
+	return nil
+}
+
+// Remove method deletes the hashes of the image id and reports whether there
+// were any.
+func (m *MIH) Remove(id uint64) bool {
+	slots, ok := m.ids[id]
+	if !ok {
+		return false
+	}
+	for _, slot := range slots {
+		words := m.items[slot].words
+		for i, table := range m.tables {
+			key := m.split.substring(words, i)
+			bucket := table[key]
+			for j, s := range bucket {
+				if s == slot {
+					bucket[j] = bucket[len(bucket)-1]
+					bucket = bucket[:len(bucket)-1]
+					break
+				}
+			}
+			if len(bucket) == 0 {
+				delete(table, key)
+			} else {
+				table[key] = bucket
+			}
+		}
+		m.items[slot] = mihItem{}
+		m.free = append(m.free, slot)
+		m.size--
+	}
+	delete(m.ids, id)
+	return true
+}
+
+// search visits the items of a query, each once.
+type search struct {
+	m     *MIH
+	hash  goimagehash.Hash
+	words []uint64
+	seen  *slots
+	found []Match
+	// probes counts the table lookups, which a scan of the items beats once
+	// they outnumber the items.
+	probes int
+}
+
+// visit computes the distance of the item of slot to the query once.
+func (s *search) visit(slot int) error {
+	if !s.seen.add(slot) {
+		return nil
+	}
+	item := s.m.items[slot]
+	d, err := distance(item.Hash, s.hash)
+	if err != nil {
+		return err
+	}
+	s.found = append(s.found, Match{item.Item, d})
+	return nil
+}
+
+// probe visits the items whose i-th substring is exactly r away from the one
+// of the query.
+func (s *search) probe(i, r int) error {
+	table, key := s.m.tables[i], s.m.split.substring(s.words, i)
+	return s.m.split.masks(i, r, func(mask uint64) error {
+		s.probes++
+		for _, slot := range table[key^mask] {
+			if err := s.visit(slot); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scan finds all the items again, without the lookups of visit.
+func (s *search) scan() error {
+	s.found = s.found[:0]
+	for _, item := range s.m.items {
+		if item.Hash == nil {
+			continue
+		}
+		d, err := distance(item.Hash, s.hash)
+		if err != nil {
+			return err
+		}
+		s.found = append(s.found, Match{item.Item, d})
+	}
+	return nil
+}
+
+// newSearch returns a search of hash, or nil when the index is empty.
+func (m *MIH) newSearch(hash goimagehash.Hash) (*search, error) {
+	if hash == nil {
+		return nil, errNilHash
+	}
+	if m.size == 0 {
+		return nil, nil
+	}
+	words, err := m.check(hash)
+	if err != nil {
+		return nil, err
+	}
+	seen, _ := m.seen.Get().(*slots)
+	if seen == nil {
+		seen = &slots{}
+	}
+	return &search{m: m, hash: hash, words: words, seen: seen}, nil
+}
+
+// close recycles the slots seen by the search.
+func (s *search) close() {
+	s.seen.clear()
+	s.m.seen.Put(s.seen)
+}
+
+// slots is a set of slots, which clears only the words it set.
+type slots struct {
+	bits  []uint64
+	words []int
+	len   int
+}
+
+// add adds slot to the set and reports whether it was not in it.
+func (s *slots) add(slot int) bool {
+	w, bit := slot/64, uint64(1)<<uint(slot%64)
+	if w >= len(s.bits) {
+		s.bits = append(s.bits, make([]uint64, w+1-len(s.bits))...)
+	}
+	if s.bits[w]&bit != 0 {
+		return false
+	}
+	if s.bits[w] == 0 {
+		s.words = append(s.words, w)
+	}
+	s.bits[w] |= bit
+	s.len++
+	return true
+}
+
+// clear empties the set.
+func (s *slots) clear() {
+	for _, w := range s.words {
+		s.bits[w] = 0
+	}
+	s.words, s.len = s.words[:0], 0
+}
+
+// RangeQuery method returns the items at most maxDistance away from hash,
+// sorted by distance then by ID.
+func (m *MIH) RangeQuery(hash goimagehash.Hash, maxDistance int) ([]Match, error) {
+	s, err := m.newSearch(hash)
+	if s == nil || err != nil {
+		return nil, err
+	}
+	defer s.close()
+	if maxDistance < 0 {
+		return nil, nil
+	}
+	if maxDistance > m.bits {
+		maxDistance = m.bits
+	}
+	probes := 0
+	for i := range m.tables {
+		for r := 0; r <= m.split.radius(i, maxDistance) && probes <= m.size; r++ {
+			probes += m.split.probes(i, r, m.size)
+		}
+	}
+	if probes > m.size {
+		err = s.scan()
+	} else {
+		for i := range m.tables {
+			for r := 0; r <= m.split.radius(i, maxDistance) && err == nil; r++ {
+				err = s.probe(i, r)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	found := s.found[:0]
+	for _, match := range s.found {
+		if match.Distance <= maxDistance {
+			found = append(found, match)
+		}
+	}
+	return sortMatches(found), nil
+}
+
+// KNN method returns the k items nearest to hash, sorted by distance then by
+// ID, which also breaks the ties at the distance of the k-th item.
+// It searches increasing distances r, probing the substrings of each one
+// once, until k items are at most r away.
+func (m *MIH) KNN(hash goimagehash.Hash, k int) ([]Match, error) {
+	s, err := m.newSearch(hash)
+	if s == nil || err != nil {
+		return nil, err
+	}
+	defer s.close()
+	if k <= 0 {
+		return nil, nil
+	}
+	// within counts the items found by distance. Those at most r away are
+	// all found once the substrings are probed for r.
+	within := make([]int, m.bits+1)
+	counted, count, n := 0, 0, len(m.tables)
+	for r := 0; r <= m.bits && count < k && s.seen.len < m.size; r++ {
+		if s.probes+m.split.probes(r%n, r/n, m.size) > m.size {
+			if err := s.scan(); err != nil {
+				return nil, err
+			}
+			// The scan found every item, so no distance is left to search.
+			within, counted = make([]int, m.bits+1), 0
+			r = m.bits
+		} else if err := s.probe(r%n, r/n); err != nil {
+			return nil, err
+		}
+		for _, match := range s.found[counted:] {
+			within[match.Distance]++
+		}
+		counted = len(s.found)
+		count += within[r]
+	}
+	// Only the items up to the distance of the k-th one need sorting.
+	cutoff, count := 0, within[0]
+	for count < k && cutoff < m.bits {
+		cutoff++
+		count += within[cutoff]
+	}
+	found := s.found[:0]
+	for _, match := range s.found {
+		if match.Distance <= cutoff {
+			found = append(found, match)
+		}
+	}
+	found = sortMatches(found)
+	if len(found) > k {
+		found = found[:k]
+	}
+	return found, nil
+}
+
+// split holds the first bit of each substring hashes are split into, then
+// the size of the hashes.
+type split []int
+
+// newSplit function returns the split of bits long hashes into n substrings,
+// 16 bits long ones when n is 0 or less.
+func newSplit(bits, n int) (split, error) {
+	if n <= 0 {
+		n = (bits + 15) / 16
+	}
+	if n > bits {
+		return nil, fmt.Errorf("%d bits hashes can not be split into %d substrings", bits, n)
+	}
+	if (bits+n-1)/n > maxSubstringBits {
+		return nil, fmt.Errorf("%d bits hashes split into %d substrings exceed %d bits substrings", bits, n, maxSubstringBits)
+	}
+	s := make(split, n+1)
+	for i := range s {
+		s[i] = i * bits / n
+	}
+	return s, nil
+}
+
+// count returns the number of substrings.
+func (s split) count() int {
+	return len(s) - 1
+}
+
+// substring returns the i-th substring of words.
+func (s split) substring(words []uint64, i int) uint64 {
+	start, length := s[i], s[i+1]-s[i]
+	w, offset := start/64, uint(start%64)
+	v := words[w] << offset
+	if int(offset)+length > 64 {
+		v |= words[w+1] >> (64 - offset)
+	}
+	return v >> uint(64-length)
+}
+
+// radius returns how far the i-th substring of a hash at most r away from
+// the query may be from the one of the query: r / substrings, less one for
+// the substrings after the first r % substrings + 1 ones.
+func (s split) radius(i, r int) int {
+	n := s.count()
+	if i <= r%n {
+		return r / n
+	}
+	return r/n - 1
+}
+
+// masks calls fn with each mask of the i-th substring with r bits set, in
+// increasing order.
+func (s split) masks(i, r int, fn func(mask uint64) error) error {
+	length := s[i+1] - s[i]
+	if r > length {
+		return nil
+	}
+	limit := uint64(1) << uint(length)
+	for mask := uint64(1)<<uint(r) - 1; mask < limit; {
+		if err := fn(mask); err != nil {
+			return err
+		}
+		if mask == 0 {
+			break
+		}
+		// Next mask with as many bits set, by Gosper's hack.
+		c := mask & -mask
+		next := mask + c
+		mask = ((next^mask)>>2)/c | next
+	}
+	return nil
+}
+
+// probes returns the number of masks of masks(i, r), or more than limit.
+func (s split) probes(i, r, limit int) int {
+	length := s[i+1] - s[i]
+	if r > length {
+		return 0
+	}
+	if r > length-r {
+		r = length - r
+	}
+	n := 1
+	for k := 1; k <= r; k++ {
+		n = n * (length - r + k) / k
+		if n > limit {
+			return limit + 1
+		}
+	}
+	return n
+}
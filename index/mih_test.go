@@ -0,0 +1,186 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"testing"
+
+	"github.com/corona10/goimagehash"
+)
+
+// liveItems returns the items whose IDs are not removed.
+func liveItems(items []Item, removed map[uint64]bool) []Item {
+	var live []Item
+	for _, item := range items {
+		if !removed[item.ID] {
+			live = append(live, item)
+		}
+	}
+	return live
+}
+
+// checkMIH compares the queries of an index with a brute force scan of items.
+func checkMIH(t *testing.T, mih *MIH, items, queries []Item, bits int) {
+	if mih.Len() != len(items) {
+		t.Errorf("MIH is expected to hold %d items but holds %d", len(items), mih.Len())
+		return
+	}
+	for _, query := range queries {
+		for _, maxDistance := range []int{0, 1, 3, 7, bits / 8, bits / 4, bits / 2, bits + 1} {
+			found, err := mih.RangeQuery(query.Hash, maxDistance)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if expected := bruteForce(t, items, query.Hash, maxDistance); !sameMatches(found, expected) {
+				t.Errorf("RangeQuery within %d of %d bits in %d substrings is expected %v but got %v", maxDistance, bits, mih.Substrings(), expected, found)
+			}
+		}
+		all := bruteForce(t, items, query.Hash, bits)
+		for _, k := range []int{1, 2, 5, 30, len(items), len(items) + 1} {
+			found, err := mih.KNN(query.Hash, k)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			expected := all
+			if k < len(all) {
+				expected = all[:k]
+			}
+			if !sameMatches(found, expected) {
+				t.Errorf("KNN of %d among %d bits in %d substrings is expected %v but got %v", k, bits, mih.Substrings(), expected, found)
+			}
+		}
+	}
+}
+
+func TestMIH(t *testing.T) {
+	for _, tt := range []struct {
+		bits, substrings, expected int
+	}{
+		{64, 0, 4},
+		{64, 2, 2},
+		{64, 5, 5},
+		{256, 0, 16},
+		{256, 11, 11},
+		{72, 0, 5},
+		{12, 0, 1},
+	} {
+		items := randomItems(600, tt.bits, int64(tt.bits))
+		// Items may share an ID, which Remove deletes together.
+		items = append(items, Item{0, items[7].Hash}, Item{3, items[5].Hash})
+		mih := NewMIH(tt.substrings)
+		for _, item := range items {
+			if err := mih.Add(item.ID, item.Hash); err != nil {
+				t.Fatalf("%s", err)
+			}
+		}
+		if mih.Substrings() != tt.expected {
+			t.Errorf("%d bits hashes are expected to be split into %d substrings but got %d", tt.bits, tt.expected, mih.Substrings())
+		}
+		queries := append(randomItems(6, tt.bits, int64(tt.bits)+1), items[5], items[300])
+		checkMIH(t, mih, items, queries, tt.bits)
+
+		removed := map[uint64]bool{}
+		for i := 0; i < len(items); i += 3 {
+			if mih.Remove(items[i].ID) != !removed[items[i].ID] {
+				t.Errorf("Remove(%d) is expected to report %v", items[i].ID, !removed[items[i].ID])
+			}
+			removed[items[i].ID] = true
+		}
+		live := liveItems(items, removed)
+		checkMIH(t, mih, live, queries, tt.bits)
+
+		// Added items reuse the removed slots.
+		for i := 0; i < len(items); i += 6 {
+			if err := mih.Add(items[i].ID, items[i].Hash); err != nil {
+				t.Fatalf("%s", err)
+			}
+			live = append(live, items[i])
+		}
+		checkMIH(t, mih, live, queries, tt.bits)
+	}
+}
+
+func TestMIHErrors(t *testing.T) {
+	mih := NewMIH(0)
+	hash := goimagehash.NewImageHash(0, goimagehash.AHash)
+	for _, query := range []func() ([]Match, error){
+		func() ([]Match, error) { return mih.RangeQuery(hash, 10) },
+		func() ([]Match, error) { return mih.KNN(hash, 10) },
+	} {
+		if found, err := query(); err != nil || len(found) != 0 {
+			t.Errorf("query of an empty index is expected nothing but got %v, %v", found, err)
+		}
+	}
+	if err := mih.Add(1, nil); err == nil {
+		t.Errorf("Add of a nil hash is expected to fail")
+	}
+	if err := mih.Add(1, goimagehash.NewExtImageHash([]uint64{1}, goimagehash.AHash, 128)); err == nil {
+		t.Errorf("Add of a hash of missing bits is expected to fail")
+	}
+	if mih.Substrings() != 0 {
+		t.Errorf("failed additions are expected not to split hashes")
+	}
+	if err := mih.Add(1, hash); err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, other := range []goimagehash.Hash{
+		goimagehash.NewImageHash(1, goimagehash.DHash),
+		goimagehash.NewExtImageHash([]uint64{1}, goimagehash.AHash, 64),
+		nil,
+	} {
+		if err := mih.Add(2, other); err == nil {
+			t.Errorf("Add of %v to an index of %v is expected to fail", other, hash.ToString())
+		}
+		if _, err := mih.RangeQuery(other, 10); err == nil {
+			t.Errorf("RangeQuery of %v in an index of %v is expected to fail", other, hash.ToString())
+		}
+		if _, err := mih.KNN(other, 10); err == nil {
+			t.Errorf("KNN of %v in an index of %v is expected to fail", other, hash.ToString())
+		}
+	}
+	if mih.Len() != 1 {
+		t.Errorf("failed additions are expected not to be counted")
+	}
+	if mih.Remove(2) {
+		t.Errorf("Remove of a missing ID is expected to report false")
+	}
+
+	for _, substrings := range []int{1, 65} {
+		if err := NewMIH(substrings).Add(1, hash); err == nil {
+			t.Errorf("Add of 64 bits hashes split into %d substrings is expected to fail", substrings)
+		}
+	}
+}
+
+func benchmarkIndex(b *testing.B, bits int, add func(id uint64, hash goimagehash.Hash) error, query func(hash goimagehash.Hash) ([]Match, error)) {
+	items := randomItems(100000, bits, 1)
+	for _, item := range items {
+		add(item.ID, item.Hash)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		query(items[i%len(items)].Hash)
+	}
+}
+
+func BenchmarkMIHRangeQuery64(b *testing.B) {
+	mih := NewMIH(0)
+	benchmarkIndex(b, 64, mih.Add, func(hash goimagehash.Hash) ([]Match, error) { return mih.RangeQuery(hash, 6) })
+}
+
+func BenchmarkMIHRangeQuery256(b *testing.B) {
+	mih := NewMIH(0)
+	benchmarkIndex(b, 256, mih.Add, func(hash goimagehash.Hash) ([]Match, error) { return mih.RangeQuery(hash, 24) })
+}
+
+func BenchmarkMIHKNN64(b *testing.B) {
+	mih := NewMIH(0)
+	benchmarkIndex(b, 64, mih.Add, func(hash goimagehash.Hash) ([]Match, error) { return mih.KNN(hash, 5) })
+}
+
+func BenchmarkBKTreeRangeQuery256(b *testing.B) {
+	tree := NewBKTree()
+	benchmarkIndex(b, 256, tree.Add, func(hash goimagehash.Hash) ([]Match, error) { return tree.RangeQuery(hash, 24) })
+}
@@ -0,0 +1,318 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+
+	"github.com/corona10/goimagehash"
+)
+
+// VPTree is a vantage-point tree of hashes, built at once from all of them.
+// Each node splits the others around the median of their distances to its
+// hash, so that the triangle inequality prunes a side at any distance,
+// which suits large hashes searched at large distances.
+// The hashes of a tree should all be of the same type, kind and size.
+type VPTree struct {
+	// items holds the nodes in preorder: the vantage point of the items
+	// [lo, hi) is items[lo], its inside is [lo+1, split) and its outside is
+	// [split, hi).
+	items []Item
+	nodes []vpNode
+	// words holds the bits of the hashes of items, width words each.
+	words []uint64
+	width int
+}
+
+// vpNode bounds the distances to the vantage point of its inside and outside.
+type vpNode struct {
+	split int
+	// inner is the largest distance inside, outer the smallest one outside.
+	inner, outer int
+}
+
+// NewVPTree function returns a VPTree of items, which are copied.
+func NewVPTree(items []Item) (*VPTree, error) {
+	t := &VPTree{items: append([]Item{}, items...), nodes: make([]vpNode, len(items))}
+	for _, item := range t.items {
+		if _, err := distance(t.items[0].Hash, item.Hash); err != nil {
+			return nil, err
+		}
+	}
+	b := vpBuilder{byDistance{t.items, make([]int, len(items))}, t.nodes, rand.New(rand.NewSource(1))}
+	b.build(0, len(items))
+	for _, item := range t.items {
+		words, _, _ := hashWords(item.Hash)
+		t.words = append(t.words, words...)
+		t.width = len(words)
+	}
+	return t, nil
+}
+
+// byDistance sorts items by their distances.
+type byDistance struct {
+	items     []Item
+	distances []int
+}
+
+func (b byDistance) Len() int { return len(b.items) }
+func (b byDistance) Swap(i, j int) {
+	b.items[i], b.items[j] = b.items[j], b.items[i]
+	b.distances[i], b.distances[j] = b.distances[j], b.distances[i]
+}
+func (b byDistance) Less(i, j int) bool { return b.distances[i] < b.distances[j] }
+
+// vpBuilder picks the vantage points of a tree at random.
+type vpBuilder struct {
+	byDistance
+	nodes []vpNode
+	rand  *rand.Rand
+}
+
+// build makes items[lo] the vantage point of the items [lo, hi) and splits
+// the others in halves.
+func (b vpBuilder) build(lo, hi int) {
+	for hi-lo > 1 {
+		b.Swap(lo, lo+b.rand.Intn(hi-lo))
+		for i := lo + 1; i < hi; i++ {
+			// The hashes are checked by NewVPTree.
+			b.distances[i], _ = distance(b.items[lo].Hash, b.items[i].Hash)
+		}
+		sort.Sort(byDistance{b.items[lo+1 : hi], b.distances[lo+1 : hi]})
+		split := lo + 1 + (hi-lo)/2
+		node := &b.nodes[lo]
+		node.split, node.inner = split, b.distances[split-1]
+		if split < hi {
+			node.outer = b.distances[split]
+		}
+		b.build(lo+1, split)
+		lo = split
+	}
+	if hi-lo == 1 {
+		b.nodes[lo].split = hi
+	}
+}
+
+// Len method returns the number of items in the tree.
+func (t *VPTree) Len() int {
+	return len(t.items)
+}
+
+// check returns the bits of hash unless it can not be compared with the
+// hashes of the tree.
+func (t *VPTree) check(hash goimagehash.Hash) ([]uint64, error) {
+	words, _, err := hashWords(hash)
+	if err != nil || len(t.items) == 0 {
+		return words, err
+	}
+	if _, err := distance(t.items[0].Hash, hash); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// distance returns the distance between the hash of items[i] and query.
+func (t *VPTree) distance(i int, query []uint64) int {
+	var out [1]int
+	goimagehash.ExtDistances(query, t.words[i*t.width:(i+1)*t.width], out[:])
+	return out[0]
+}
+
+// RangeQuery method returns the items at most maxDistance away from hash,
+// sorted by distance then by ID.
+func (t *VPTree) RangeQuery(hash goimagehash.Hash, maxDistance int) ([]Match, error) {
+	query, err := t.check(hash)
+	if err != nil {
+		return nil, err
+	}
+	var found []Match
+	stack := [][2]int{{0, len(t.items)}}
+	for len(stack) > 0 {
+		lo, hi := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+		if lo >= hi {
+			continue
+		}
+		node := t.nodes[lo]
+		d := t.distance(lo, query)
+		if d <= maxDistance {
+			found = append(found, Match{t.items[lo], d})
+		}
+		// The items inside are at least d - inner away from hash and the
+		// ones outside at least outer - d.
+		if d-node.inner <= maxDistance {
+			stack = append(stack, [2]int{lo + 1, node.split})
+		}
+		if node.outer-d <= maxDistance {
+			stack = append(stack, [2]int{node.split, hi})
+		}
+	}
+	return sortMatches(found), nil
+}
+
+// farthest is a heap of matches, the farthest then largest ID first.
+type farthest []Match
+
+func (f farthest) Len() int            { return len(f) }
+func (f farthest) Less(i, j int) bool  { return matches(f).Less(j, i) }
+func (f farthest) Swap(i, j int)       { f[i], f[j] = f[j], f[i] }
+func (f *farthest) Push(x interface{}) { *f = append(*f, x.(Match)) }
+func (f *farthest) Pop() interface{} {
+	m := (*f)[len(*f)-1]
+	*f = (*f)[:len(*f)-1]
+	return m
+}
+
+// KNN method returns the k items nearest to hash among the ones at most
+// maxDistance away, sorted by distance then by ID, which also breaks the ties
+// at the distance of the k-th item.
+func (t *VPTree) KNN(hash goimagehash.Hash, k, maxDistance int) ([]Match, error) {
+	query, err := t.check(hash)
+	if err != nil || k <= 0 {
+		return nil, err
+	}
+	s := vpSearch{t, query, k, maxDistance, make(farthest, 0, k)}
+	s.search(0, len(t.items))
+	found := []Match(s.found)
+	sort.Sort(matches(found))
+	return found, nil
+}
+
+// vpSearch holds the k nearest items found, at most tau away from query.
+type vpSearch struct {
+	*VPTree
+	query []uint64
+	k     int
+	tau   int
+	found farthest
+}
+
+// search finds the nearest items among [lo, hi), visiting first the side of
+// the vantage point hash is likely in, so that tau prunes the other one more.
+func (s *vpSearch) search(lo, hi int) {
+	if lo >= hi {
+		return
+	}
+	node := s.nodes[lo]
+	d := s.distance(lo, s.query)
+	if d <= s.tau {
+		m := Match{s.items[lo], d}
+		if len(s.found) < s.k {
+			heap.Push(&s.found, m)
+		} else if matches([]Match{m, s.found[0]}).Less(0, 1) {
+			s.found[0] = m
+			heap.Fix(&s.found, 0)
+		}
+		if len(s.found) == s.k {
+			// Items as far as the k-th one may still replace it by ID.
+			s.tau = s.found[0].Distance
+		}
+	}
+	if d <= node.inner {
+		s.inside(lo, node, d)
+		s.outside(hi, node, d)
+	} else {
+		s.outside(hi, node, d)
+		s.inside(lo, node, d)
+	}
+}
+
+// inside searches the inside of the node of lo, which is at least
+// d - node.inner away from hash.
+func (s *vpSearch) inside(lo int, node vpNode, d int) {
+	if d-node.inner <= s.tau {
+		s.search(lo+1, node.split)
+	}
+}
+
+// outside searches the outside of a node ending at hi, which is at least
+// node.outer - d away from hash.
+func (s *vpSearch) outside(hi int, node vpNode, d int) {
+	if node.outer-d <= s.tau {
+		s.search(node.split, hi)
+	}
+}
+
+// vpDump is the serialization of a VPTree.
+type vpDump struct {
+	Ext    bool
+	Kind   goimagehash.Kind
+	Bits   int
+	IDs    []uint64
+	Words  []uint64
+	Splits []int
+	Inners []int
+	Outers []int
+}
+
+var errCorruptVPTree = errors.New("corrupt VPTree")
+
+// Dump method writes a binary serialization into w io.Writer.
+func (t *VPTree) Dump(w io.Writer) error {
+	d := vpDump{Words: t.words}
+	if len(t.items) > 0 {
+		switch h := t.items[0].Hash.(type) {
+		case *goimagehash.ImageHash:
+			d.Kind, d.Bits = h.GetKind(), h.Bits()
+		case *goimagehash.ExtImageHash:
+			d.Ext, d.Kind, d.Bits = true, h.GetKind(), h.Bits()
+		}
+	}
+	for i, item := range t.items {
+		node := t.nodes[i]
+		d.IDs = append(d.IDs, item.ID)
+		d.Splits = append(d.Splits, node.split)
+		d.Inners = append(d.Inners, node.inner)
+		d.Outers = append(d.Outers, node.outer)
+	}
+	return gob.NewEncoder(w).Encode(d)
+}
+
+// LoadVPTree function loads a VPTree from io.Reader.
+func LoadVPTree(r io.Reader) (*VPTree, error) {
+	var d vpDump
+	if err := gob.NewDecoder(r).Decode(&d); err != nil {
+		return nil, err
+	}
+	n, width := len(d.IDs), (d.Bits+63)/64
+	if len(d.Splits) != n || len(d.Inners) != n || len(d.Outers) != n {
+		return nil, errCorruptVPTree
+	}
+	if n > 0 && (d.Bits <= 0 || (!d.Ext && d.Bits != 64) || len(d.Words) != n*width) {
+		return nil, fmt.Errorf("%s: %d words for %d hashes of %d bits", errCorruptVPTree, len(d.Words), n, d.Bits)
+	}
+	t := &VPTree{items: make([]Item, n), nodes: make([]vpNode, n), words: d.Words, width: width}
+	for i, id := range d.IDs {
+		words := d.Words[i*width : (i+1)*width : (i+1)*width]
+		if d.Ext {
+			t.items[i] = Item{id, goimagehash.NewExtImageHash(words, d.Kind, d.Bits)}
+		} else {
+			t.items[i] = Item{id, goimagehash.NewImageHash(words[0], d.Kind)}
+		}
+		t.nodes[i] = vpNode{d.Splits[i], d.Inners[i], d.Outers[i]}
+	}
+	if !t.valid(0, n) {
+		return nil, errCorruptVPTree
+	}
+	return t, nil
+}
+
+// valid reports whether the nodes of [lo, hi) split it within its bounds.
+func (t *VPTree) valid(lo, hi int) bool {
+	for lo < hi {
+		split := t.nodes[lo].split
+		if split <= lo || split > hi || !t.valid(lo+1, split) {
+			return false
+		}
+		lo = split
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/corona10/goimagehash"
+)
+
+func TestVPTree(t *testing.T) {
+	for _, bits := range []int{64, 72, 1024} {
+		items := randomItems(1500, bits, int64(bits))
+		items = append(items, Item{0, items[7].Hash}, Item{3, items[5].Hash})
+		tree, err := NewVPTree(items)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		var buf bytes.Buffer
+		if err := tree.Dump(&buf); err != nil {
+			t.Fatalf("%s", err)
+		}
+		loaded, err := LoadVPTree(&buf)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		queries := append(randomItems(10, bits, int64(bits)+1), items[5], items[300])
+		for _, tree := range []*VPTree{tree, loaded} {
+			if tree.Len() != len(items) {
+				t.Errorf("VPTree is expected to hold %d items but holds %d", len(items), tree.Len())
+			}
+			for _, query := range queries {
+				for _, maxDistance := range []int{0, 3, bits / 8, bits / 3, bits} {
+					found, err := tree.RangeQuery(query.Hash, maxDistance)
+					if err != nil {
+						t.Fatalf("%s", err)
+					}
+					within := bruteForce(t, items, query.Hash, maxDistance)
+					if !sameMatches(found, within) {
+						t.Errorf("RangeQuery within %d of %d bits is expected %d items but got %d", maxDistance, bits, len(within), len(found))
+					}
+					for _, k := range []int{1, 2, 10, len(items) + 1} {
+						found, err := tree.KNN(query.Hash, k, maxDistance)
+						if err != nil {
+							t.Fatalf("%s", err)
+						}
+						expected := within
+						if k < len(within) {
+							expected = within[:k]
+						}
+						if !sameMatches(found, expected) {
+							t.Errorf("KNN of %d within %d of %d bits is expected %v but got %v", k, maxDistance, bits, expected, found)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestVPTreeErrors(t *testing.T) {
+	hash := goimagehash.NewImageHash(0, goimagehash.AHash)
+	for _, items := range [][]Item{
+		{{1, hash}, {2, nil}},
+		{{1, nil}},
+		{{1, hash}, {2, goimagehash.NewImageHash(0, goimagehash.DHash)}},
+		{{1, hash}, {2, goimagehash.NewExtImageHash([]uint64{0}, goimagehash.AHash, 64)}},
+	} {
+		if _, err := NewVPTree(items); err == nil {
+			t.Errorf("NewVPTree(%v) is expected to fail", items)
+		}
+	}
+
+	empty, err := NewVPTree(nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if found, err := empty.KNN(hash, 3, 64); err != nil || len(found) != 0 {
+		t.Errorf("KNN of an empty tree is expected nothing but got %v, %v", found, err)
+	}
+	if _, err := empty.RangeQuery(nil, 3); err == nil {
+		t.Errorf("RangeQuery of a nil hash is expected to fail")
+	}
+	tree, err := NewVPTree([]Item{{1, hash}})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := tree.KNN(goimagehash.NewImageHash(0, goimagehash.PHash), 1, 64); err == nil {
+		t.Errorf("KNN of a hash of another kind is expected to fail")
+	}
+
+	var buf bytes.Buffer
+	if err := tree.Dump(&buf); err != nil {
+		t.Fatalf("%s", err)
+	}
+	data := buf.Bytes()
+	for _, corrupt := range [][]byte{data[:len(data)/2], nil} {
+		if _, err := LoadVPTree(bytes.NewReader(corrupt)); err == nil {
+			t.Errorf("LoadVPTree of %d bytes of %d is expected to fail", len(corrupt), len(data))
+		}
+	}
+	tree.nodes[0].split = 2
+	buf.Reset()
+	if err := tree.Dump(&buf); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := LoadVPTree(&buf); err == nil {
+		t.Errorf("LoadVPTree of a node splitting out of its bounds is expected to fail")
+	}
+}
+
+// familyItems returns n reproducible items of hashes of the given bits, made
+// of families of near duplicates which share ancestors further away, like
+// the hashes of edited copies of images.
+func familyItems(n, bits int, seed int64) []Item {
+	r := rand.New(rand.NewSource(seed))
+	var items []Item
+	var grow func(hash []uint64, flips int)
+	grow = func(hash []uint64, flips int) {
+		if flips < 8 {
+			items = append(items, Item{uint64(len(items)), goimagehash.NewExtImageHash(hash, goimagehash.AHash, bits)})
+			return
+		}
+		for c := 0; c < 8 && len(items) < n; c++ {
+			child := append([]uint64{}, hash...)
+			for f := 0; f < flips; f++ {
+				b := r.Intn(bits)
+				child[b/64] ^= 1 << uint(b%64)
+			}
+			grow(child, flips/3)
+		}
+	}
+	for len(items) < n {
+		root := make([]uint64, bits/64)
+		for j := range root {
+			root[j] = uint64(r.Int63())<<1 ^ uint64(r.Int63())
+		}
+		grow(root, bits/4)
+	}
+	return items
+}
+
+func benchmarkIndex1024(b *testing.B, query func(items []Item) func(hash goimagehash.Hash) ([]Match, error)) {
+	items := familyItems(20000, 1024, 1)
+	q := query(items)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q(items[i*7919%len(items)].Hash)
+	}
+}
+
+func BenchmarkVPTreeKNN1024(b *testing.B) {
+	benchmarkIndex1024(b, func(items []Item) func(hash goimagehash.Hash) ([]Match, error) {
+		tree, _ := NewVPTree(items)
+		return func(hash goimagehash.Hash) ([]Match, error) { return tree.KNN(hash, 5, 300) }
+	})
+}
+
+func BenchmarkVPTreeRangeQuery1024(b *testing.B) {
+	benchmarkIndex1024(b, func(items []Item) func(hash goimagehash.Hash) ([]Match, error) {
+		tree, _ := NewVPTree(items)
+		return func(hash goimagehash.Hash) ([]Match, error) { return tree.RangeQuery(hash, 200) }
+	})
+}
+
+func BenchmarkMIHRangeQuery1024(b *testing.B) {
+	benchmarkIndex1024(b, func(items []Item) func(hash goimagehash.Hash) ([]Match, error) {
+		mih := NewMIH(0)
+		for _, item := range items {
+			mih.Add(item.ID, item.Hash)
+		}
+		return func(hash goimagehash.Hash) ([]Match, error) { return mih.RangeQuery(hash, 200) }
+	})
+}
+
+func BenchmarkBKTreeRangeQuery1024(b *testing.B) {
+	benchmarkIndex1024(b, func(items []Item) func(hash goimagehash.Hash) ([]Match, error) {
+		tree := NewBKTree()
+		for _, item := range items {
+			tree.Add(item.ID, item.Hash)
+		}
+		return func(hash goimagehash.Hash) ([]Match, error) { return tree.RangeQuery(hash, 200) }
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jpeg
+
+import "math"
+
+const blockSize = 8 * 8
+
+// block holds the 8x8 coefficients of a block, in natural order once decoded.
+type block [blockSize]int32
+
+// reducedCos holds for each output size s of 1, 2 and 4 pixels the basis
+// functions c(u) * cos((2x+1)uπ/2s) of the inverse DCT, where c(0) = 1 and
+// c(u) = √2 otherwise. Sampling the 8 points inverse DCT at the centers of
+// the s x s sub-blocks only involves the s x s lowest frequencies.
+var reducedCos [5][4][4]float64
+
+func init() {
+	for _, s := range []int{1, 2, 4} {
+		for x := 0; x < s; x++ {
+			for u := 0; u < s; u++ {
+				c := math.Sqrt2
+				if u == 0 {
+					c = 1
+				}
+				reducedCos[s][x][u] = c * math.Cos(float64((2*x+1)*u)*math.Pi/float64(2*s))
+			}
+		}
+	}
+}
+
+// reducedIDCT writes the s x s pixels of the dequantized block b, level
+// shifted by +128 and clipped to [0, 255], to dst.
+func reducedIDCT(b *block, dst []uint8, s int) {
+	basis := &reducedCos[s]
+	var tmp [4][4]float64
+	for v := 0; v < s; v++ {
+		for x := 0; x < s; x++ {
+			sum := 0.0
+			for u := 0; u < s; u++ {
+				sum += float64(b[v*8+u]) * basis[x][u]
+			}
+			tmp[v][x] = sum
+		}
+	}
+	for y := 0; y < s; y++ {
+		for x := 0; x < s; x++ {
+			sum := 0.0
+			for v := 0; v < s; v++ {
+				sum += tmp[v][x] * basis[y][v]
+			}
+			p := math.Floor(sum/8+0.5) + 128
+			switch {
+			case p < 0:
+				p = 0
+			case p > 255:
+				p = 255
+			}
+			dst[y*s+x] = uint8(p)
+		}
+	}
+}
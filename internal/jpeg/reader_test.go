@@ -0,0 +1,99 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jpeg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	stdjpeg "image/jpeg"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+// encode returns img encoded as a JPEG image.
+func encode(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := stdjpeg.Encode(&buf, img, &stdjpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	return buf.Bytes()
+}
+
+// meanError returns the mean absolute difference of the gray levels of small
+// and the box downscaled gray levels of large.
+func meanError(small, large image.Image, denom int) float64 {
+	sb, lb := small.Bounds(), large.Bounds()
+	sum, n := 0.0, 0
+	for y := 0; y < lb.Dy()/denom; y++ {
+		for x := 0; x < lb.Dx()/denom; x++ {
+			avg := 0.0
+			for yy := 0; yy < denom; yy++ {
+				for xx := 0; xx < denom; xx++ {
+					c := color.GrayModel.Convert(large.At(lb.Min.X+x*denom+xx, lb.Min.Y+y*denom+yy)).(color.Gray)
+					avg += float64(c.Y)
+				}
+			}
+			avg /= float64(denom * denom)
+			c := color.GrayModel.Convert(small.At(sb.Min.X+x, sb.Min.Y+y)).(color.Gray)
+			sum += math.Abs(float64(c.Y) - avg)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+func TestDecodeScaled(t *testing.T) {
+	sample, err := ioutil.ReadFile("../../_examples/sample1.jpg")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	gray := image.NewGray(image.Rect(0, 0, 75, 50))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i % 75 * 3)
+	}
+	rgb := image.NewRGBA(image.Rect(0, 0, 75, 50))
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 75; x++ {
+			rgb.SetRGBA(x, y, color.RGBA{uint8(x * 3), uint8(y * 5), uint8((x + y) * 2), 255})
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"sample1.jpg", sample},
+		{"gray", encode(t, gray)},
+		{"4:2:0", encode(t, rgb)},
+	} {
+		full, err := stdjpeg.Decode(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		for _, denom := range []int{2, 4, 8} {
+			small, err := DecodeScaled(bytes.NewReader(tt.data), denom)
+			if err != nil {
+				t.Errorf("%s at 1/%d: %s", tt.name, denom, err)
+				continue
+			}
+			fb := full.Bounds()
+			expected := image.Rect(0, 0, (fb.Dx()+denom-1)/denom, (fb.Dy()+denom-1)/denom)
+			if small.Bounds() != expected {
+				t.Errorf("%s at 1/%d is expected bounds %v but got %v", tt.name, denom, expected, small.Bounds())
+			}
+			// The reduced inverse DCT samples a low pass version of the
+			// blocks which is close to, but not exactly, their average.
+			if e := meanError(small, full, denom); e > 6 {
+				t.Errorf("%s at 1/%d differs from the full decode by %.2f gray levels on average", tt.name, denom, e)
+			}
+		}
+	}
+
+	if _, err := DecodeScaled(bytes.NewReader(sample), 3); err == nil {
+		t.Errorf("DecodeScaled with denominator 3 is expected to fail")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// kindInfo describes a registered Kind.
+type kindInfo struct {
+	name      string
+	shortCode string
+}
+
+var (
+	kindMu sync.RWMutex
+	// kinds is indexed by Kind. The built-in kinds must stay in the same
+	// order as their constants since their values are serialized.
+	kinds = []kindInfo{
+		Unknown: {name: "unknown", shortCode: ""},
+		AHash:   {name: "ahash", shortCode: "a"},
+		PHash:   {name: "phash", shortCode: "p"},
+		DHash:   {name: "dhash", shortCode: "d"},
+		WHash:   {name: "whash", shortCode: "w"},
+	}
+)
+
+// RegisterKind function registers a new kind of hash and returns its value.
+// name is used by Kind.String and ParseKind, shortCode is the single
+// character prefix used by the string representation of hashes.
+// Kinds are numbered in registration order, so custom kinds should be
+// registered in the same order by every program sharing dumped hashes.
+func RegisterKind(name, shortCode string) (Kind, error) {
+	if name == "" || strings.ContainsAny(name, ": \t\n") {
+		return Unknown, fmt.Errorf("invalid kind name %q", name)
+	}
+	if len(shortCode) != 1 || shortCode == ":" || shortCode == " " {
+		return Unknown, fmt.Errorf("kind short code should be a single character but got %q", shortCode)
+	}
+
+	kindMu.Lock()
+	defer kindMu.Unlock()
+	for _, info := range kinds {
+		if info.name == name {
+			return Unknown, fmt.Errorf("kind %q is already registered", name)
+		}
+		if info.shortCode == shortCode {
+			return Unknown, fmt.Errorf("kind short code %q is already registered", shortCode)
+		}
+	}
+	kinds = append(kinds, kindInfo{name: name, shortCode: shortCode})
+	return Kind(len(kinds) - 1), nil
+}
+
+// ParseKind function returns the kind registered with the given name or short code.
+func ParseKind(s string) (Kind, error) {
+	kindMu.RLock()
+	defer kindMu.RUnlock()
+	for k, info := range kinds {
+		if info.name == s || (info.shortCode != "" && info.shortCode == s) {
+			return Kind(k), nil
+		}
+	}
+	return Unknown, errors.New("unknown kind " + s)
+}
+
+// String method returns the registered name of the kind.
+func (k Kind) String() string {
+	info, ok := k.info()
+	if !ok {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return info.name
+}
+
+// registered method reports whether the kind has been registered.
+func (k Kind) registered() bool {
+	_, ok := k.info()
+	return ok
+}
+
+// shortCode method returns the prefix used by the string representation.
+// Unregistered kinds have an empty short code like Unknown.
+func (k Kind) shortCode() string {
+	info, _ := k.info()
+	return info.shortCode
+}
+
+func (k Kind) info() (kindInfo, bool) {
+	kindMu.RLock()
+	defer kindMu.RUnlock()
+	if k < 0 || int(k) >= len(kinds) {
+		return kindInfo{}, false
+	}
+	return kinds[k], true
+}
+
+// kindFromShortCode returns the kind of the given short code.
+// Unknown is returned for unregistered short codes.
+func kindFromShortCode(code string) Kind {
+	if code == "" {
+		return Unknown
+	}
+	kindMu.RLock()
+	defer kindMu.RUnlock()
+	for k, info := range kinds {
+		if info.shortCode == code {
+			return Kind(k)
+		}
+	}
+	return Unknown
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKindString(t *testing.T) {
+	for _, tt := range []struct {
+		kind     Kind
+		expected string
+	}{
+		{Unknown, "unknown"},
+		{AHash, "ahash"},
+		{PHash, "phash"},
+		{DHash, "dhash"},
+		{WHash, "whash"},
+		{Kind(-1), "Kind(-1)"},
+		{Kind(1000), "Kind(1000)"},
+	} {
+		if got := tt.kind.String(); got != tt.expected {
+			t.Errorf("Kind(%d).String() is expected %v but got %v", int(tt.kind), tt.expected, got)
+		}
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	for _, tt := range []struct {
+		input    string
+		expected Kind
+		err      bool
+	}{
+		{"ahash", AHash, false},
+		{"a", AHash, false},
+		{"phash", PHash, false},
+		{"p", PHash, false},
+		{"dhash", DHash, false},
+		{"whash", WHash, false},
+		{"unknown", Unknown, false},
+		{"", Unknown, true},
+		{"nohash", Unknown, true},
+	} {
+		kind, err := ParseKind(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseKind(%q) returned unexpected error %v", tt.input, err)
+		}
+		if kind != tt.expected {
+			t.Errorf("ParseKind(%q) is expected %v but got %v", tt.input, tt.expected, kind)
+		}
+	}
+}
+
+func TestRegisterKind(t *testing.T) {
+	kind, err := RegisterKind("testhash", "t")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if kind.String() != "testhash" {
+		t.Errorf("Registered kind is expected as testhash but got %v", kind)
+	}
+	if parsed, _ := ParseKind("t"); parsed != kind {
+		t.Errorf("ParseKind(t) is expected %v but got %v", kind, parsed)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		shortCode string
+	}{
+		{"testhash", "u"},
+		{"otherhash", "t"},
+		{"otherhash", "a"},
+		{"", "u"},
+		{"bad:name", "u"},
+		{"otherhash", ""},
+		{"otherhash", "uv"},
+		{"otherhash", ":"},
+	} {
+		if _, err := RegisterKind(tt.name, tt.shortCode); err == nil {
+			t.Errorf("RegisterKind(%q, %q) should return an error", tt.name, tt.shortCode)
+		}
+	}
+
+	hash := NewImageHash(0xe48ae53c05e502f7, kind)
+	str := hash.ToString()
+	if str != "t:e48ae53c05e502f7" {
+		t.Errorf("Got invalid hex string %v", str)
+	}
+	reHash, err := ImageHashFromString(str)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if reHash.GetKind() != kind || reHash.GetHash() != hash.GetHash() {
+		t.Errorf("Original and unserialized objects should be identical, got %v", reHash.ToString())
+	}
+
+	extHash := NewExtImageHash([]uint64{0xe48ae53c05e502f7, 1}, kind, 128)
+	extStr := extHash.ToString()
+	reExtHash, err := ExtImageHashFromString(extStr)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if reExtHash.GetKind() != kind || reExtHash.ToString() != extStr {
+		t.Errorf("Original and unserialized objects should be identical, got %v", reExtHash.ToString())
+	}
+}
+
+func TestLoadUnregisteredKind(t *testing.T) {
+	var b bytes.Buffer
+	if err := NewImageHash(1, Kind(1000)).Dump(&b); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := LoadImageHash(&b); err == nil {
+		t.Errorf("Should got error for an unregistered kind")
+	}
+
+	b.Reset()
+	if err := NewExtImageHash([]uint64{1}, Kind(1000), 64).Dump(&b); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := LoadExtImageHash(&b); err == nil {
+		t.Errorf("Should got error for an unregistered kind")
+	}
+}
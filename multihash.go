@@ -0,0 +1,226 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/corona10/goimagehash/transforms"
+)
+
+// HashSpec selects a hash computed by ComputeHashes. Zero Width and Height
+// select the 64bits hash of Kind, otherwise the extended hash of the size.
+type HashSpec struct {
+	Kind          Kind
+	Width, Height int
+}
+
+// String method returns the name of the hash as understood by LookupHasher.
+func (s HashSpec) String() string {
+	if s.Width == 0 && s.Height == 0 {
+		return s.Kind.String()
+	}
+	return fmt.Sprintf("%v-%dx%d", s.Kind, s.Width, s.Height)
+}
+
+// specHashers maps kinds to the functions computing their 64bits and extended hashes.
+var specHashers = map[Kind]struct {
+	simple func(img image.Image, o *options) (*ImageHash, error)
+	ext    func(img image.Image, width, height int, o *options) (*ExtImageHash, error)
+}{
+	AHash: {averageHash, extAverageHash},
+	DHash: {differenceHash, extDifferenceHash},
+	PHash: {perceptionHash, extPerceptionHash},
+	WHash: {waveletHash, extWaveletHash},
+}
+
+// grayscaleSize returns the size of the gray scale array the hash of the spec
+// is computed from, or false when the hash has its own pipeline.
+func (s HashSpec) grayscaleSize(o *options) (width, height int, ok bool, err error) {
+	if s.Width < 0 || s.Height < 0 || (s.Width == 0) != (s.Height == 0) {
+		return 0, 0, false, fmt.Errorf("invalid size of hash %v", s)
+	}
+	if o.pythonCompat || o.fixedPoint {
+		return 0, 0, false, nil
+	}
+	simple := s.Width == 0
+	switch s.Kind {
+	case AHash:
+		if simple {
+			return 8, 8, true, nil
+		}
+		return s.Width, s.Height, true, nil
+	case DHash:
+		if simple {
+			return 9, 8, true, nil
+		}
+		return s.Width + 1, s.Height, true, nil
+	case PHash:
+		if simple {
+			return 64, 64, true, nil
+		}
+		imgSize := s.Width * s.Height
+		if imgSize&(imgSize-1) != 0 {
+			return 0, 0, false, errors.New("width * height should be power of 2")
+		}
+		return imgSize, imgSize, true, nil
+	}
+	if _, ok := specHashers[s.Kind]; !ok {
+		return 0, 0, false, fmt.Errorf("unsupported kind %v", s.Kind)
+	}
+	return 0, 0, false, nil
+}
+
+// hash returns the hash of the spec computed on its own.
+func (s HashSpec) hash(img image.Image, o *options) (Hash, error) {
+	fns := specHashers[s.Kind]
+	if s.Width == 0 {
+		hash, err := fns.simple(img, o)
+		if err != nil {
+			return nil, err
+		}
+		return hash, nil
+	}
+	hash, err := fns.ext(img, s.Width, s.Height, o)
+	if err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// hashGrayscale returns the hash of the spec computed from the gray scale
+// array of grayscaleSize, which is left unchanged.
+func (s HashSpec) hashGrayscale(gray transforms.Target, o *options) (Hash, error) {
+	pixels := grayRows(gray.Pixels, gray.Width, gray.Height)
+	simple := s.Width == 0
+	switch s.Kind {
+	case AHash:
+		if simple {
+			return NewImageHash(averageBits(pixels, 8, 8)[0], AHash), nil
+		}
+		return NewExtImageHash(averageBits(pixels, s.Width, s.Height), AHash, s.Width*s.Height), nil
+	case DHash:
+		if simple {
+			return NewImageHash(differenceBits(pixels, 64)[0], DHash), nil
+		}
+		return NewExtImageHash(differenceBits(pixels, s.Width*s.Height), DHash, s.Width*s.Height), nil
+	default:
+		if simple {
+			buf := pixelPool64.Get().(*[]float64)
+			copy(*buf, gray.Pixels)
+			phash := NewImageHash(perceptionBits64(buf), PHash)
+			pixelPool64.Put(buf)
+			return phash, nil
+		}
+		imgSize := s.Width * s.Height
+		buf := perceptionBuffer(imgSize)
+		copy(*buf, gray.Pixels)
+		phash, err := perceptionBits(*buf, s.Width, s.Height, o)
+		putPerceptionBuffer(buf, imgSize)
+		if err != nil {
+			return nil, err
+		}
+		return NewExtImageHash(phash, PHash, imgSize), nil
+	}
+}
+
+// Hashes holds the hashes computed by ComputeHashes.
+type Hashes struct {
+	// Specs and Hashes are in the order the hashes were requested.
+	Specs  []HashSpec
+	Hashes []Hash
+}
+
+// Get method returns the hash of spec, or nil when it was not requested.
+func (h *Hashes) Get(spec HashSpec) Hash {
+	for i, s := range h.Specs {
+		if s == spec {
+			return h.Hashes[i]
+		}
+	}
+	return nil
+}
+
+// MultiHasher computes several hashes of an image at once.
+type MultiHasher struct {
+	specs []HashSpec
+	opts  *options
+}
+
+// NewMultiHasher function returns a MultiHasher computing the hashes of specs configured by opts.
+func NewMultiHasher(specs []HashSpec, opts ...Option) *MultiHasher {
+	return &MultiHasher{specs: append([]HashSpec{}, specs...), opts: newOptions(opts)}
+}
+
+// ComputeHashes function returns the hashes of img selected by specs, with
+// the default options. See MultiHasher.Hash.
+func ComputeHashes(img image.Image, specs ...HashSpec) (*Hashes, error) {
+	return NewMultiHasher(specs).Hash(img)
+}
+
+// Hash method returns the hashes of img. The hashes are identical to the ones
+// of the single hash functions, but img is trimmed, converted to gray scale
+// and downsampled in a single pass shared by the average, difference and
+// perception hashes. Wavelet hashes and hashes computed with
+// WithPythonCompat or WithFixedPoint follow their own pipeline.
+func (m *MultiHasher) Hash(img image.Image) (*Hashes, error) {
+	o := m.opts
+	if err := o.canceled(); err != nil {
+		return nil, err
+	}
+	if img == nil {
+		return nil, errors.New("image object can not be nil")
+	}
+	img = o.prepare(img)
+	// img is trimmed once for all the hashes.
+	prepared := *o
+	prepared.trim = false
+
+	// grays holds the distinct gray scale arrays and indices the one of each
+	// spec, or -1 when the hash is computed on its own.
+	var grays []transforms.Target
+	indices := make([]int, len(m.specs))
+	for i, spec := range m.specs {
+		width, height, ok, err := spec.grayscaleSize(o)
+		if err != nil {
+			return nil, err
+		}
+		indices[i] = -1
+		if !ok {
+			continue
+		}
+		for j, gray := range grays {
+			if gray.Width == width && gray.Height == height {
+				indices[i] = j
+				break
+			}
+		}
+		if indices[i] < 0 {
+			indices[i] = len(grays)
+			grays = append(grays, transforms.Target{Pixels: make([]float64, width*height), Width: width, Height: height})
+		}
+	}
+	if len(grays) > 0 {
+		if err := o.resampler().ResampleAll(img, grays); err != nil {
+			return nil, o.failure(err)
+		}
+	}
+
+	hashes := &Hashes{Specs: append([]HashSpec{}, m.specs...), Hashes: make([]Hash, len(m.specs))}
+	for i, spec := range m.specs {
+		var err error
+		if indices[i] < 0 {
+			hashes.Hashes[i], err = spec.hash(img, &prepared)
+		} else {
+			hashes.Hashes[i], err = spec.hashGrayscale(grays[indices[i]], &prepared)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"image"
+	"testing"
+)
+
+// multiSpecs mixes 64bits and extended hashes of every built-in kind,
+// including specs sharing a gray scale array (phash and phash-8x8).
+var multiSpecs = []HashSpec{
+	{AHash, 0, 0},
+	{DHash, 0, 0},
+	{PHash, 0, 0},
+	{WHash, 0, 0},
+	{AHash, 16, 16},
+	{DHash, 16, 8},
+	{PHash, 8, 8},
+	{PHash, 16, 16},
+	{PHash, 32, 32},
+	{WHash, 16, 16},
+}
+
+func TestComputeHashes(t *testing.T) {
+	img := loadImage(t, "_examples/sample1.jpg")
+	sub := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}).SubImage(image.Rect(40, 30, 300, 200))
+
+	for _, tt := range []struct {
+		opts  []Option
+		specs []HashSpec
+	}{
+		{nil, multiSpecs},
+		{[]Option{WithInterpolation(Bicubic), WithLuma(Rec709)}, multiSpecs},
+		{[]Option{WithTrim(nil, 8)}, multiSpecs},
+		{[]Option{WithFixedPoint()}, multiSpecs},
+		// Python compatible hashes should be square.
+		{[]Option{WithPythonCompat()}, []HashSpec{{AHash, 0, 0}, {DHash, 16, 16}, {PHash, 8, 8}, {WHash, 0, 0}}},
+	} {
+		opts := tt.opts
+		for _, src := range []image.Image{img, sub} {
+			hashes, err := NewMultiHasher(tt.specs, opts...).Hash(src)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if len(hashes.Hashes) != len(tt.specs) {
+				t.Fatalf("ComputeHashes is expected %d hashes but got %d", len(tt.specs), len(hashes.Hashes))
+			}
+			for i, spec := range tt.specs {
+				hasher, err := LookupHasher(spec.String(), opts...)
+				if err != nil {
+					t.Fatalf("%s", err)
+				}
+				expected, err := hasher.Hash(src)
+				if err != nil {
+					t.Fatalf("%s", err)
+				}
+				hash := hashes.Get(spec)
+				if hash != hashes.Hashes[i] {
+					t.Errorf("Get(%v) does not return the hash of the spec", spec)
+				}
+				if hash.GetKind() != expected.GetKind() || hash.Bits() != expected.Bits() || hash.ToString() != expected.ToString() {
+					t.Errorf("%v is expected %v but got %v", spec, expected.ToString(), hash.ToString())
+				}
+			}
+		}
+	}
+
+	hashes, err := ComputeHashes(img, HashSpec{Kind: AHash})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, ok := hashes.Get(HashSpec{Kind: AHash}).(*ImageHash); !ok {
+		t.Errorf("64bits hashes are expected to be *ImageHash")
+	}
+	if hash := hashes.Get(HashSpec{Kind: DHash}); hash != nil {
+		t.Errorf("Get of a spec which was not requested is expected nil but got %v", hash)
+	}
+}
+
+func TestComputeHashesErrors(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for _, tt := range []struct {
+		img   image.Image
+		specs []HashSpec
+	}{
+		{nil, []HashSpec{{AHash, 0, 0}}},
+		{img, []HashSpec{{AHash, 8, 0}}},
+		{img, []HashSpec{{DHash, -8, -8}}},
+		{img, []HashSpec{{AHash, 0, 0}, {PHash, 6, 6}}},
+		{img, []HashSpec{{Unknown, 0, 0}}},
+	} {
+		if _, err := ComputeHashes(tt.img, tt.specs...); err == nil {
+			t.Errorf("ComputeHashes(%v) is expected to fail", tt.specs)
+		}
+	}
+}
+
+var benchmarkSpecs = []HashSpec{
+	{AHash, 0, 0},
+	{DHash, 0, 0},
+	{PHash, 0, 0},
+	{AHash, 16, 16},
+	{DHash, 16, 16},
+	{PHash, 16, 16},
+}
+
+func BenchmarkComputeHashes(b *testing.B) {
+	img := loadImage(b, "_examples/sample1.jpg")
+	hasher := NewMultiHasher(benchmarkSpecs)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := hasher.Hash(img); err != nil {
+			b.Fatalf("%s", err)
+		}
+	}
+}
+
+func BenchmarkComputeHashesSeparately(b *testing.B) {
+	img := loadImage(b, "_examples/sample1.jpg")
+	hashers := make([]Hasher, len(benchmarkSpecs))
+	for i, spec := range benchmarkSpecs {
+		hashers[i], _ = LookupHasher(spec.String())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, hasher := range hashers {
+			if _, err := hasher.Hash(img); err != nil {
+				b.Fatalf("%s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/corona10/goimagehash/transforms"
+)
+
+// Interpolation describes the filter used to shrink images before hashing.
+type Interpolation int
+
+const (
+	// NearestNeighbor is a enum value of the nearest-neighbor interpolation.
+	NearestNeighbor Interpolation = iota
+	// Bilinear is a enum value of the bilinear interpolation. It is the default.
+	Bilinear
+	// Bicubic is a enum value of the bicubic interpolation.
+	Bicubic
+	// Lanczos2 is a enum value of the Lanczos interpolation (a=2).
+	Lanczos2
+	// Lanczos3 is a enum value of the Lanczos interpolation (a=3).
+	// It is the closest to the ANTIALIAS filter used by Python imagehash.
+	Lanczos3
+	// AreaAverage is a enum value of the area-average (box) interpolation.
+	AreaAverage
+)
+
+// Luma describes the formula converting colors to gray scale before hashing.
+// Every formula reads the full 16 bits of the color channels except Rec601Legacy.
+type Luma int
+
+const (
+	// Rec601Legacy is a enum value of the Rec. 601 luma computed on channels
+	// truncated to 8 bits. It is the default.
+	Rec601Legacy Luma = iota
+	// Rec601 is a enum value of the Rec. 601 luma.
+	Rec601
+	// Rec709 is a enum value of the Rec. 709 luma.
+	Rec709
+	// RGBAverage is a enum value of the plain average of the channels.
+	RGBAverage
+	// CIELightness is a enum value of the CIE 1976 lightness L*.
+	CIELightness
+	// LinearLight is a enum value of the relative luminance of linearized sRGB.
+	LinearLight
+)
+
+// Option configures how a hash is computed.
+type Option func(*options)
+
+// options holds the settings of a hash computation.
+type options struct {
+	interpolation Interpolation
+	luma          Luma
+	pythonCompat  bool
+	fixedPoint    bool
+	// parallelism bounds the goroutines of large DCTs, see WithParallelism.
+	parallelism int
+
+	// trim enables the cropping of uniform borders, see WithTrim.
+	trim          bool
+	trimColor     color.Color
+	trimTolerance int
+
+	// done and err abort the computation, see WithContext.
+	done <-chan struct{}
+	err  func() error
+}
+
+// defaultOptions returns the settings used by the hash functions without options.
+func defaultOptions() *options {
+	return &options{interpolation: Bilinear}
+}
+
+func newOptions(opts []Option) *options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithInterpolation function returns an Option selecting the filter used to resize images.
+func WithInterpolation(interp Interpolation) Option {
+	return func(o *options) {
+		o.interpolation = interp
+	}
+}
+
+// WithLuma function returns an Option selecting the gray scale conversion of images.
+func WithLuma(luma Luma) Option {
+	return func(o *options) {
+		o.luma = luma
+	}
+}
+
+// WithParallelism function returns an Option bounding to n the goroutines
+// computing the DCT of perception hashes too large for static DCT tables.
+// 1 computes them on the calling goroutine only and 0, the default, uses
+// runtime.GOMAXPROCS(0) goroutines.
+func WithParallelism(n int) Option {
+	return func(o *options) {
+		o.parallelism = n
+	}
+}
+
+// WithTrim function returns an Option cropping the uniform borders of images,
+// such as letterbox bars or canvas margins, before they are hashed. A border
+// pixel matches border when every channel differs by at most tolerance on a
+// 0-255 scale. A nil border uses the color of the top-left pixel.
+// Use Trim to know the part of an image which is hashed.
+func WithTrim(border color.Color, tolerance int) Option {
+	return func(o *options) {
+		o.trim = true
+		o.trimColor = border
+		o.trimTolerance = tolerance
+	}
+}
+
+// Trim function returns img without its uniform borders, as hashed with
+// WithTrim(border, tolerance), and the rectangle it covers in img.
+func Trim(img image.Image, border color.Color, tolerance int) (image.Image, image.Rectangle) {
+	r := transforms.TrimBorder(img, border, tolerance)
+	return transforms.Crop(img, r), r
+}
+
+// WithPythonCompat function returns an Option computing the hashes of the
+// Python imagehash library (average_hash, dhash, phash and whash with their
+// default parameters). Extended hashes should be square and their width is
+// used as hash_size. The interpolation and the luma are ignored since
+// imagehash always converts with Pillow's "L" mode and resizes with its
+// ANTIALIAS filter. The hashes are identical to the ones of imagehash for
+// images of the size it resizes to; larger images are resized by a port of
+// Pillow's filter which is not checked against Pillow, so that their hashes
+// may differ by a few bits.
+func WithPythonCompat() Option {
+	return func(o *options) {
+		o.pythonCompat = true
+	}
+}
+
+// filters maps interpolations to the filters of transforms.Resample.
+var filters = [...]transforms.Filter{
+	NearestNeighbor: transforms.NearestNeighbor,
+	Bilinear:        transforms.Bilinear,
+	Bicubic:         transforms.Bicubic,
+	Lanczos2:        transforms.Lanczos2,
+	Lanczos3:        transforms.Lanczos3,
+	AreaAverage:     transforms.Box,
+}
+
+// filter returns the resampling filter of the configured interpolation.
+func (o *options) filter() transforms.Filter {
+	if o.interpolation < 0 || int(o.interpolation) >= len(filters) {
+		return transforms.Bilinear
+	}
+	return filters[o.interpolation]
+}
+
+// lumas maps lumas to the formulas of transforms.Resampler.
+var lumas = [...]transforms.Luma{
+	Rec601Legacy: transforms.Rec601Legacy,
+	Rec601:       transforms.Rec601,
+	Rec709:       transforms.Rec709,
+	RGBAverage:   transforms.RGBAverage,
+	CIELightness: transforms.CIELightness,
+	LinearLight:  transforms.LinearLight,
+}
+
+// resampler returns the resampler of the configured interpolation and luma.
+func (o *options) resampler() transforms.Resampler {
+	r := transforms.Resampler{Filter: o.filter(), Done: o.done}
+	if o.luma >= 0 && int(o.luma) < len(lumas) {
+		r.Luma = lumas[o.luma]
+	}
+	return r
+}
+
+// prepare returns the part of img to be hashed.
+func (o *options) prepare(img image.Image) image.Image {
+	if !o.trim || img == nil {
+		return img
+	}
+	return transforms.Crop(img, transforms.TrimBorder(img, o.trimColor, o.trimTolerance))
+}
+
+// canceled returns the cancellation error once the computation is aborted.
+func (o *options) canceled() error {
+	if o.done == nil {
+		return nil
+	}
+	select {
+	case <-o.done:
+		return o.err()
+	default:
+		return nil
+	}
+}
+
+// failure returns err, translated to the cancellation error when it reports
+// that the computation was aborted.
+func (o *options) failure(err error) error {
+	if err == transforms.ErrCanceled && o.err != nil {
+		return o.err()
+	}
+	return err
+}
+
+// grayscale returns img resized to width x height as a gray scale array.
+func (o *options) grayscale(img image.Image, width, height int) ([][]float64, error) {
+	flattens := make([]float64, width*height)
+	if err := o.resampler().Resample(img, flattens, width, height); err != nil {
+		return nil, o.failure(err)
+	}
+	return grayRows(flattens, width, height), nil
+}
+
+// grayRows returns the rows of the width x height values of flattens.
+func grayRows(flattens []float64, width, height int) [][]float64 {
+	pixels := make([][]float64, height)
+	for i := range pixels {
+		pixels[i] = flattens[i*width : (i+1)*width]
+	}
+	return pixels
+}
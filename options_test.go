@@ -0,0 +1,186 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"testing"
+)
+
+func loadImage(t testing.TB, path string) image.Image {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer file.Close()
+	img, err := jpeg.Decode(file)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return img
+}
+
+func TestWithInterpolation(t *testing.T) {
+	img1 := loadImage(t, "_examples/sample1.jpg")
+	img2 := loadImage(t, "_examples/sample3.jpg")
+
+	defaultHash, _ := PerceptionHash(img1)
+	bilinearHash, err := NewPerceptionHasher(WithInterpolation(Bilinear)).Hash(img1)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if bilinearHash.ToString() != defaultHash.ToString() {
+		t.Errorf("Bilinear hash is expected %v but got %v", defaultHash.ToString(), bilinearHash.ToString())
+	}
+
+	for _, interp := range []Interpolation{NearestNeighbor, Bilinear, Bicubic, Lanczos2, Lanczos3, AreaAverage} {
+		for _, name := range []string{"ahash", "dhash", "phash", "ahash-256", "dhash-256", "phash-256"} {
+			hasher, err := LookupHasher(name, WithInterpolation(interp))
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			hash1, err := hasher.Hash(img1)
+			if err != nil {
+				t.Errorf("%s with interpolation %d: %v", name, interp, err)
+				continue
+			}
+			hash2, err := hasher.Hash(img2)
+			if err != nil {
+				t.Errorf("%s with interpolation %d: %v", name, interp, err)
+				continue
+			}
+
+			// sample1 and sample3 are the same picture in different sizes.
+			var distance int
+			switch h := hash1.(type) {
+			case *ImageHash:
+				distance, err = h.Distance(hash2.(*ImageHash))
+			case *ExtImageHash:
+				distance, err = h.Distance(hash2.(*ExtImageHash))
+			}
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			if distance > hash1.Bits()/8 {
+				t.Errorf("%s with interpolation %d: distance between similar images is too large: %d", name, interp, distance)
+			}
+		}
+	}
+}
+
+func TestWithLuma(t *testing.T) {
+	img1 := loadImage(t, "_examples/sample1.jpg")
+	img2 := loadImage(t, "_examples/sample3.jpg")
+
+	defaultHash, _ := PerceptionHash(img1)
+	legacyHash, err := NewPerceptionHasher(WithLuma(Rec601Legacy)).Hash(img1)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if legacyHash.ToString() != defaultHash.ToString() {
+		t.Errorf("Rec601Legacy hash is expected %v but got %v", defaultHash.ToString(), legacyHash.ToString())
+	}
+
+	for _, luma := range []Luma{Rec601, Rec709, RGBAverage, CIELightness, LinearLight} {
+		for _, name := range []string{"ahash", "dhash", "phash", "ahash-256", "dhash-256", "phash-256"} {
+			hasher, err := LookupHasher(name, WithLuma(luma))
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			hash1, err := hasher.Hash(img1)
+			if err != nil {
+				t.Errorf("%s with luma %d: %v", name, luma, err)
+				continue
+			}
+			hash2, err := hasher.Hash(img2)
+			if err != nil {
+				t.Errorf("%s with luma %d: %v", name, luma, err)
+				continue
+			}
+
+			var distance int
+			switch h := hash1.(type) {
+			case *ImageHash:
+				distance, err = h.Distance(hash2.(*ImageHash))
+			case *ExtImageHash:
+				distance, err = h.Distance(hash2.(*ExtImageHash))
+			}
+			if err != nil {
+				t.Errorf("%v", err)
+			}
+			if distance > hash1.Bits()/4 {
+				t.Errorf("%s with luma %d: distance between similar images is too large: %d", name, luma, distance)
+			}
+		}
+	}
+}
+
+func TestWithTrim(t *testing.T) {
+	src := loadImage(t, "_examples/sample1.jpg")
+	bounds := src.Bounds()
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, src, bounds.Min, draw.Src)
+
+	// The same picture with black letterbox bars and with a white margin.
+	content := image.Rect(0, 40, bounds.Dx(), 40+bounds.Dy())
+	letterbox := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()+80))
+	draw.Draw(letterbox, letterbox.Bounds(), image.Black, image.ZP, draw.Src)
+	draw.Draw(letterbox, content, img, bounds.Min, draw.Src)
+	margin := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+60, bounds.Dy()+60))
+	draw.Draw(margin, margin.Bounds(), image.White, image.ZP, draw.Src)
+	draw.Draw(margin, bounds.Add(image.Pt(30, 30)), img, bounds.Min, draw.Src)
+
+	if _, r := Trim(letterbox, color.Black, 8); r != content {
+		t.Errorf("Trim of the letterbox is expected %v but got %v", content, r)
+	}
+
+	for _, name := range []string{"ahash", "dhash", "phash", "ahash-256", "dhash-256", "phash-256"} {
+		plain, err := LookupHasher(name)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		trimmed, err := LookupHasher(name, WithTrim(nil, 8))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		expected, err := plain.Hash(img)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, framed := range []image.Image{letterbox, margin} {
+			hash, err := trimmed.Hash(framed)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if hash.ToString() != expected.ToString() {
+				t.Errorf("%s of a framed image with trimming is expected %v but got %v", name, expected.ToString(), hash.ToString())
+			}
+		}
+	}
+}
+
+func TestWithParallelism(t *testing.T) {
+	img := loadImage(t, "_examples/sample1.jpg")
+	// A 32x32 hash needs a DCT of size 1024, larger than the static tables.
+	expected, err := NewExtPerceptionHasher(32, 32).Hash(img)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, n := range []int{1, 2, 7} {
+		hash, err := NewExtPerceptionHasher(32, 32, WithParallelism(n)).Hash(img)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+		if hash.ToString() != expected.ToString() {
+			t.Errorf("ExtPerceptionHash with parallelism %d is expected %v but got %v", n, expected.ToString(), hash.ToString())
+		}
+	}
+}
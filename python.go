@@ -0,0 +1,408 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/corona10/goimagehash/transforms"
+)
+
+// The functions of this file compute the hashes of the Python imagehash
+// library (https://github.com/JohannesBuchner/imagehash): images are
+// converted to Pillow's "L" mode, resized with a port of Pillow's ANTIALIAS
+// filter in 8 bits fixed point and hashed with the same arithmetic as numpy,
+// scipy and pywt. The hashes of images which already have the size imagehash
+// resizes them to are checked bit for bit against imagehash, see
+// testdata/python. The resizing of larger images is not, so their hashes
+// may differ by a few bits.
+// Decoders can produce different pixels for the same file, JPEG in
+// particular, so identical results require identical decoded pixels.
+
+// pythonGray converts img to "L" mode and resizes it as
+// image.convert("L").resize((width, height), ANTIALIAS) does.
+func pythonGray(img image.Image, width, height int) *image.Gray {
+	return transforms.PILResize(transforms.PILGray(img), width, height)
+}
+
+// pythonMedian returns the median of values as numpy.median does.
+func pythonMedian(values []float64) float64 {
+	tmp := make([]float64, len(values))
+	copy(tmp, values)
+	sort.Float64s(tmp)
+	n := len(tmp)
+	if n%2 == 1 {
+		return tmp[n/2]
+	}
+	return (tmp[n/2-1] + tmp[n/2]) / 2
+}
+
+// bitsToExtHash packs bits in row-major order, the first bit being the most significant.
+func bitsToExtHash(diff []bool, kind Kind) *ExtImageHash {
+	hash := make([]uint64, (len(diff)+63)/64)
+	for idx, set := range diff {
+		if set {
+			hash[idx/64] |= 1 << uint(63-idx%64)
+		}
+	}
+	return NewExtImageHash(hash, kind, len(diff))
+}
+
+func checkPythonHashSize(hashSize int) error {
+	if hashSize < 2 {
+		return errors.New("hash size should be at least 2")
+	}
+	return nil
+}
+
+// pythonAverageHash reproduces imagehash.average_hash(img, hashSize).
+func pythonAverageHash(img image.Image, hashSize int) (*ExtImageHash, error) {
+	if img == nil {
+		return nil, errors.New("image object can not be nil")
+	}
+	if err := checkPythonHashSize(hashSize); err != nil {
+		return nil, err
+	}
+	gray := pythonGray(img, hashSize, hashSize)
+	sum := 0.0
+	for _, p := range gray.Pix {
+		sum += float64(p)
+	}
+	avg := sum / float64(len(gray.Pix))
+
+	diff := make([]bool, len(gray.Pix))
+	for idx, p := range gray.Pix {
+		diff[idx] = float64(p) > avg
+	}
+	return bitsToExtHash(diff, AHash), nil
+}
+
+// pythonDifferenceHash reproduces imagehash.dhash(img, hashSize).
+func pythonDifferenceHash(img image.Image, hashSize int) (*ExtImageHash, error) {
+	if img == nil {
+		return nil, errors.New("image object can not be nil")
+	}
+	if err := checkPythonHashSize(hashSize); err != nil {
+		return nil, err
+	}
+	gray := pythonGray(img, hashSize+1, hashSize)
+	diff := make([]bool, 0, hashSize*hashSize)
+	for y := 0; y < hashSize; y++ {
+		row := gray.Pix[y*gray.Stride:]
+		for x := 0; x < hashSize; x++ {
+			diff = append(diff, row[x+1] > row[x])
+		}
+	}
+	return bitsToExtHash(diff, DHash), nil
+}
+
+// pythonDCT computes the first n coefficients of the unnormalized DCT-II
+// of scipy.fftpack.dct: y[k] = 2 * sum(x[i] * cos(pi * k * (2i + 1) / (2N))).
+func pythonDCT(x []float64, n int, out []float64) {
+	size := float64(len(x))
+	for k := 0; k < n; k++ {
+		sum := 0.0
+		for i, v := range x {
+			sum += float64(v * math.Cos(math.Pi*float64(k)*float64(2*i+1)/(2*size)))
+		}
+		out[k] = 2 * sum
+	}
+}
+
+// pythonLowFreq returns the hashSize x hashSize lowest frequencies of
+// dct(dct(pixels, axis=0), axis=1) of gray, computed with dct.
+func pythonLowFreq(gray *image.Gray, hashSize int, dct func(x []float64, n int, out []float64)) []float64 {
+	imgSize := gray.Bounds().Dx()
+	cols := make([][]float64, imgSize)
+	column := make([]float64, imgSize)
+	for x := 0; x < imgSize; x++ {
+		for y := 0; y < imgSize; y++ {
+			column[y] = float64(gray.Pix[y*gray.Stride+x])
+		}
+		cols[x] = make([]float64, hashSize)
+		dct(column, hashSize, cols[x])
+	}
+	lowFreq := make([]float64, hashSize*hashSize)
+	row := make([]float64, imgSize)
+	for y := 0; y < hashSize; y++ {
+		for x := 0; x < imgSize; x++ {
+			row[x] = cols[x][y]
+		}
+		dct(row, hashSize, lowFreq[y*hashSize:(y+1)*hashSize])
+	}
+	return lowFreq
+}
+
+// pythonPerceptionHash reproduces imagehash.phash(img, hashSize, highfreq_factor=4).
+func pythonPerceptionHash(img image.Image, hashSize int) (*ExtImageHash, error) {
+	if img == nil {
+		return nil, errors.New("image object can not be nil")
+	}
+	if err := checkPythonHashSize(hashSize); err != nil {
+		return nil, err
+	}
+	imgSize := hashSize * 4
+	lowFreq := pythonLowFreq(pythonGray(img, imgSize, imgSize), hashSize, pythonDCT)
+	med := pythonMedian(lowFreq)
+	diff := make([]bool, len(lowFreq))
+	for idx, p := range lowFreq {
+		diff[idx] = p > med
+	}
+	return bitsToExtHash(diff, PHash), nil
+}
+
+// haarCoefficient is pywt's Haar filter coefficient 1/sqrt(2).
+// Products are converted explicitly to float64 in the wavelet transforms
+// below to prevent fused multiply-add, which pywt does not use.
+const haarCoefficient = 0.7071067811865476
+
+// haarDWT2 returns the approximation and the details (da, ad, dd) of one
+// level of pywt.dwt2(pixels, 'haar') on a size x size matrix.
+func haarDWT2(pixels [][]float64) (ll, da, ad, dd [][]float64) {
+	n := len(pixels) / 2
+	// Along axis 0 first, then axis 1, with pywt's order of operations.
+	a := make([][]float64, n)
+	d := make([][]float64, n)
+	for i := 0; i < n; i++ {
+		a[i] = make([]float64, len(pixels))
+		d[i] = make([]float64, len(pixels))
+		for j := range pixels {
+			x0, x1 := pixels[2*i][j], pixels[2*i+1][j]
+			a[i][j] = float64(haarCoefficient*x1) + float64(haarCoefficient*x0)
+			d[i][j] = float64(-haarCoefficient*x1) + float64(haarCoefficient*x0)
+		}
+	}
+	split := func(m [][]float64) (lo, hi [][]float64) {
+		lo, hi = make([][]float64, n), make([][]float64, n)
+		for i := range m {
+			lo[i], hi[i] = make([]float64, n), make([]float64, n)
+			for j := 0; j < n; j++ {
+				x0, x1 := m[i][2*j], m[i][2*j+1]
+				lo[i][j] = float64(haarCoefficient*x1) + float64(haarCoefficient*x0)
+				hi[i][j] = float64(-haarCoefficient*x1) + float64(haarCoefficient*x0)
+			}
+		}
+		return lo, hi
+	}
+	ll, ad = split(a)
+	da, dd = split(d)
+	return ll, da, ad, dd
+}
+
+// haarIDWT2 inverts haarDWT2 as pywt.idwt2 does.
+func haarIDWT2(ll, da, ad, dd [][]float64) [][]float64 {
+	n := len(ll)
+	merge := func(lo, hi [][]float64) [][]float64 {
+		out := make([][]float64, len(lo))
+		for i := range lo {
+			out[i] = make([]float64, 2*len(lo[i]))
+			for j := range lo[i] {
+				out[i][2*j] = float64(haarCoefficient*lo[i][j]) + float64(haarCoefficient*hi[i][j])
+				out[i][2*j+1] = float64(haarCoefficient*lo[i][j]) + float64(-haarCoefficient*hi[i][j])
+			}
+		}
+		return out
+	}
+	// Along axis 1 first, then axis 0.
+	a := merge(ll, ad)
+	d := merge(da, dd)
+	out := make([][]float64, 2*n)
+	for i := 0; i < n; i++ {
+		out[2*i] = make([]float64, 2*n)
+		out[2*i+1] = make([]float64, 2*n)
+		for j := 0; j < 2*n; j++ {
+			out[2*i][j] = float64(haarCoefficient*a[i][j]) + float64(haarCoefficient*d[i][j])
+			out[2*i+1][j] = float64(haarCoefficient*a[i][j]) + float64(-haarCoefficient*d[i][j])
+		}
+	}
+	return out
+}
+
+// pythonWaveletHash reproduces imagehash.whash(img, hashSize) with the
+// default 'haar' mode and remove_max_haar_ll=True.
+func pythonWaveletHash(img image.Image, hashSize int) (*ExtImageHash, error) {
+	if img == nil {
+		return nil, errors.New("image object can not be nil")
+	}
+	if hashSize <= 0 || hashSize&(hashSize-1) != 0 {
+		return nil, errors.New("hash size should be power of 2")
+	}
+	bounds := img.Bounds()
+	minSize := bounds.Dx()
+	if bounds.Dy() < minSize {
+		minSize = bounds.Dy()
+	}
+	if minSize <= 0 {
+		return nil, errors.New("image should not be empty")
+	}
+	imageScale := 1 << uint(log2(minSize))
+	if imageScale < hashSize {
+		imageScale = hashSize
+	}
+	llMaxLevel := log2(imageScale)
+	level := log2(hashSize)
+	dwtLevel := llMaxLevel - level
+
+	gray := pythonGray(img, imageScale, imageScale)
+	pixels := make([][]float64, imageScale)
+	for y := range pixels {
+		pixels[y] = make([]float64, imageScale)
+		for x := range pixels[y] {
+			pixels[y][x] = float64(gray.Pix[y*gray.Stride+x]) / 255.
+		}
+	}
+
+	// Remove the lowest frequency LL(max_ll).
+	type details struct{ da, ad, dd [][]float64 }
+	levels := make([]details, llMaxLevel)
+	ll := pixels
+	for i := 0; i < llMaxLevel; i++ {
+		var d details
+		ll, d.da, d.ad, d.dd = haarDWT2(ll)
+		levels[i] = d
+	}
+	for i := range ll {
+		for j := range ll[i] {
+			ll[i][j] *= 0
+		}
+	}
+	for i := llMaxLevel - 1; i >= 0; i-- {
+		ll = haarIDWT2(ll, levels[i].da, levels[i].ad, levels[i].dd)
+	}
+
+	// Use LL(K) as frequencies, where K is log2(hashSize).
+	for i := 0; i < dwtLevel; i++ {
+		ll, _, _, _ = haarDWT2(ll)
+	}
+	flattens := make([]float64, 0, hashSize*hashSize)
+	for _, row := range ll {
+		flattens = append(flattens, row...)
+	}
+	med := pythonMedian(flattens)
+	diff := make([]bool, len(flattens))
+	for idx, p := range flattens {
+		diff[idx] = p > med
+	}
+	return bitsToExtHash(diff, WHash), nil
+}
+
+// log2 returns the integer part of the base 2 logarithm of a positive n.
+func log2(n int) int {
+	l := 0
+	for n > 1 {
+		n >>= 1
+		l++
+	}
+	return l
+}
+
+// pythonImageHash converts the result of a 64bits Python compatible hash to an ImageHash.
+func pythonImageHash(hash *ExtImageHash, err error) (*ImageHash, error) {
+	if err != nil {
+		return nil, err
+	}
+	return NewImageHash(hash.hash[0], hash.kind), nil
+}
+
+// pythonHashSize returns the hash_size of a Python compatible extended hash.
+func pythonHashSize(width, height int) (int, error) {
+	if width != height {
+		return 0, errors.New("Python compatible hashes should be square")
+	}
+	return width, nil
+}
+
+// pythonHex formats the bits of a hash as str(imagehash.ImageHash) does.
+func pythonHex(hash []uint64, size int) string {
+	width := (size + 3) / 4
+	pad := width*4 - size
+	out := make([]byte, 0, width)
+	nibble := 0
+	for i := 0; i < width*4; i++ {
+		nibble <<= 1
+		if idx := i - pad; idx >= 0 && hash[idx/64]>>uint(63-idx%64)&1 == 1 {
+			nibble |= 1
+		}
+		if i%4 == 3 {
+			out = append(out, "0123456789abcdef"[nibble])
+			nibble = 0
+		}
+	}
+	return string(out)
+}
+
+// parsePythonHex parses a hex string as imagehash.hex_to_hash does.
+func parsePythonHex(s string) ([]uint64, int, error) {
+	hashSize := int(math.Sqrt(float64(len(s) * 4)))
+	size := hashSize * hashSize
+	if size == 0 {
+		return nil, 0, errors.New("Couldn't parse string " + s)
+	}
+	pad := len(s)*4 - size
+	hash := make([]uint64, (size+63)/64)
+	for i := 0; i < len(s); i++ {
+		var v uint64
+		c := s[i]
+		switch {
+		case '0' <= c && c <= '9':
+			v = uint64(c - '0')
+		case 'a' <= c && c <= 'f':
+			v = uint64(c - 'a' + 10)
+		case 'A' <= c && c <= 'F':
+			v = uint64(c - 'A' + 10)
+		default:
+			return nil, 0, errors.New("Couldn't parse string " + s)
+		}
+		for b := 0; b < 4; b++ {
+			if v>>uint(3-b)&1 == 0 {
+				continue
+			}
+			idx := i*4 + b - pad
+			if idx < 0 {
+				return nil, 0, fmt.Errorf("hex string %s has more than %d bits", s, size)
+			}
+			hash[idx/64] |= 1 << uint(63-idx%64)
+		}
+	}
+	return hash, size, nil
+}
+
+// ToPythonString method returns the hex representation used by Python imagehash.
+func (h *ImageHash) ToPythonString() string {
+	return pythonHex([]uint64{h.hash}, 64)
+}
+
+// ToPythonString method returns the hex representation used by Python imagehash.
+func (h *ExtImageHash) ToPythonString() string {
+	return pythonHex(h.hash, h.bits)
+}
+
+// ImageHashFromPythonString returns an image hash of the given kind from the
+// hex representation used by Python imagehash. The hash should have 64 bits.
+func ImageHashFromPythonString(s string, kind Kind) (*ImageHash, error) {
+	hash, size, err := parsePythonHex(s)
+	if err != nil {
+		return nil, err
+	}
+	if size != 64 {
+		return nil, fmt.Errorf("hex string %s should have 64 bits but got %d", s, size)
+	}
+	return NewImageHash(hash[0], kind), nil
+}
+
+// ExtImageHashFromPythonString returns a big hash of the given kind from the
+// hex representation used by Python imagehash. Like imagehash.hex_to_hash,
+// the hash is assumed to be square and its size is deduced from the length of s.
+func ExtImageHashFromPythonString(s string, kind Kind) (*ExtImageHash, error) {
+	hash, size, err := parsePythonHex(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewExtImageHash(hash, kind, size), nil
+}
@@ -0,0 +1,253 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPythonGolden(t *testing.T) {
+	file, err := os.Open("testdata/python/golden.txt")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer file.Close()
+
+	newHasher := map[string]func(width, height int, opts ...Option) Hasher{
+		"average_hash": NewExtAverageHasher,
+		"dhash":        NewExtDifferenceHasher,
+		"phash":        NewExtPerceptionHasher,
+		"whash":        NewExtWaveletHasher,
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var name, fn, expected string
+		var hashSize int
+		if _, err := fmt.Sscan(line, &name, &fn, &hashSize, &expected); err != nil {
+			t.Fatalf("invalid golden line %q: %v", line, err)
+		}
+
+		imgFile, err := os.Open(filepath.Join("testdata/python", name))
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		img, _, err := image.Decode(imgFile)
+		imgFile.Close()
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		hash, err := newHasher[fn](hashSize, hashSize, WithPythonCompat()).Hash(img)
+		if err != nil {
+			t.Errorf("%s(%s, %d): %v", fn, name, hashSize, err)
+			continue
+		}
+		if got := hash.(*ExtImageHash).ToPythonString(); got != expected {
+			t.Errorf("%s(%s, %d) is expected %v but got %v", fn, name, hashSize, expected, got)
+		}
+
+		if hashSize == 8 {
+			hasher, _ := LookupHasher(map[string]string{
+				"average_hash": "ahash", "dhash": "dhash", "phash": "phash", "whash": "whash",
+			}[fn], WithPythonCompat())
+			hash, err := hasher.Hash(img)
+			if err != nil {
+				t.Errorf("%s(%s, %d): %v", fn, name, hashSize, err)
+				continue
+			}
+			if got := hash.(*ImageHash).ToPythonString(); got != expected {
+				t.Errorf("64bits %s(%s) is expected %v but got %v", fn, name, expected, got)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("%s", err)
+	}
+}
+
+func TestPythonCompatHash(t *testing.T) {
+	img1 := loadImage(t, "_examples/sample1.jpg")
+	img2 := loadImage(t, "_examples/sample3.jpg")
+
+	for _, name := range []string{"ahash", "dhash", "phash", "whash", "ahash-256", "dhash-256", "phash-256", "whash-256"} {
+		hasher, err := LookupHasher(name, WithPythonCompat())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		hash1, err := hasher.Hash(img1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		hash2, err := hasher.Hash(img2)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if hash1.GetKind() != hasher.Kind() {
+			t.Errorf("%s: kind is expected %v but got %v", name, hasher.Kind(), hash1.GetKind())
+		}
+
+		var distance int
+		switch h := hash1.(type) {
+		case *ImageHash:
+			distance, err = h.Distance(hash2.(*ImageHash))
+		case *ExtImageHash:
+			distance, err = h.Distance(hash2.(*ExtImageHash))
+		}
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		if distance > hash1.Bits()/8 {
+			t.Errorf("%s: distance between similar images is too large: %d", name, distance)
+		}
+	}
+
+	for _, hasher := range []Hasher{
+		NewExtAverageHasher(16, 8, WithPythonCompat()),
+		NewExtWaveletHasher(16, 8),
+		NewExtWaveletHasher(6, 6),
+	} {
+		if _, err := hasher.Hash(img1); err == nil {
+			t.Errorf("Should got error for an invalid Python compatible hash size")
+		}
+	}
+	if _, err := NewWaveletHasher().Hash(nil); err == nil {
+		t.Errorf("Error should be got.")
+	}
+}
+
+func TestPythonString(t *testing.T) {
+	for _, tt := range []struct {
+		str      string
+		bits     int
+		expected string
+	}{
+		{"c8d4e38d62f78912", 64, "c8d4e38d62f78912"},
+		{"0000000000000001", 64, "0000000000000001"},
+		{"1ffffff", 25, "1ffffff"},
+		{"0A5A5A5", 25, "0a5a5a5"},
+		{"69bc4ca40f31a32a80845578bbb40062bd85c3b9565bd688ea3f1a0b1725677b", 256, "69bc4ca40f31a32a80845578bbb40062bd85c3b9565bd688ea3f1a0b1725677b"},
+		{"0ffffffffffffffff", 64, "ffffffffffffffff"},
+	} {
+		hash, err := ExtImageHashFromPythonString(tt.str, AHash)
+		if err != nil {
+			t.Errorf("ExtImageHashFromPythonString(%q): %v", tt.str, err)
+			continue
+		}
+		if hash.Bits() != tt.bits {
+			t.Errorf("ExtImageHashFromPythonString(%q) is expected %d bits but got %d", tt.str, tt.bits, hash.Bits())
+		}
+		if got := hash.ToPythonString(); got != tt.expected {
+			t.Errorf("Python string of %q is expected %q but got %q", tt.str, tt.expected, got)
+		}
+	}
+
+	hash, err := ImageHashFromPythonString("c8d4e38d62f78912", PHash)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if hash.GetHash() != 0xc8d4e38d62f78912 || hash.GetKind() != PHash {
+		t.Errorf("Got invalid hash %v", hash.ToString())
+	}
+	if hash.ToPythonString() != "c8d4e38d62f78912" {
+		t.Errorf("Got invalid Python string %v", hash.ToPythonString())
+	}
+
+	for _, str := range []string{"", "g", "xyz0000000000000", "2ffffff", "fffffffffffffffff", "0000000000000000000000000000000000000000000000000000000000000000"} {
+		if _, err := ImageHashFromPythonString(str, AHash); err == nil {
+			t.Errorf("ImageHashFromPythonString(%q) should return an error", str)
+		}
+	}
+}
+
+// fftDCT computes the first n coefficients of the same DCT-II as pythonDCT
+// through a radix-2 FFT, as FFTPACK does, so that its rounding differs from
+// the direct sums of pythonDCT. len(x) should be a power of 2.
+func fftDCT(x []float64, n int, out []float64) {
+	size := len(x)
+	v := make([]complex128, size)
+	for i := 0; i < size/2; i++ {
+		v[i] = complex(x[2*i], 0)
+		v[size-1-i] = complex(x[2*i+1], 0)
+	}
+	for i, j := 1, 0; i < size; i++ {
+		bit := size >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			v[i], v[j] = v[j], v[i]
+		}
+	}
+	for length := 2; length <= size; length <<= 1 {
+		angle := -2 * math.Pi / float64(length)
+		for start := 0; start < size; start += length {
+			for k := 0; k < length/2; k++ {
+				w := complex(math.Cos(angle*float64(k)), math.Sin(angle*float64(k)))
+				a, b := v[start+k], v[start+k+length/2]*w
+				v[start+k], v[start+k+length/2] = a+b, a-b
+			}
+		}
+	}
+	for k := 0; k < n; k++ {
+		angle := -math.Pi * float64(k) / float64(2*size)
+		out[k] = 2 * real(v[k]*complex(math.Cos(angle), math.Sin(angle)))
+	}
+}
+
+func TestPythonDCTBorderline(t *testing.T) {
+	names, err := filepath.Glob("testdata/python/*.png")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, name := range names {
+		imgFile, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		img, _, err := image.Decode(imgFile)
+		imgFile.Close()
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		for _, hashSize := range []int{8, 16} {
+			gray := pythonGray(img, hashSize*4, hashSize*4)
+			lowFreq := pythonLowFreq(gray, hashSize, pythonDCT)
+			fft := pythonLowFreq(gray, hashSize, fftDCT)
+
+			// The bits of the hash should not depend on the rounding of
+			// the DCT: every coefficient should stay away from the median.
+			med, fftMed := pythonMedian(lowFreq), pythonMedian(fft)
+			scale := 0.0
+			for _, p := range lowFreq {
+				scale = math.Max(scale, math.Abs(p))
+			}
+			for i, p := range lowFreq {
+				if math.Abs(p-fft[i]) > 1e-12*scale {
+					t.Errorf("phash(%s, %d) coefficient %d is %v but %v with a FFT based DCT", name, hashSize, i, p, fft[i])
+				}
+				if (p > med) != (fft[i] > fftMed) {
+					t.Errorf("phash(%s, %d) bit %d differs with a FFT based DCT", name, hashSize, i)
+				}
+				if math.Abs(p-med) < 1e-9*scale {
+					t.Errorf("phash(%s, %d) bit %d is borderline: %v against a median of %v", name, hashSize, i, p, med)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	// Register the formats of the standard library for HashReader. Other
+	// formats, TIFF included, are left to the importers, see HashReader.
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"os"
+
+	scaledjpeg "github.com/corona10/goimagehash/internal/jpeg"
+	"github.com/corona10/goimagehash/transforms"
+)
+
+// ReadOption configures how HashReader and HashFile decode images.
+type ReadOption func(*readOptions)
+
+// readOptions holds the settings of the image decoding.
+type readOptions struct {
+	reducedSize   int
+	thumbnailSize int
+
+	maxWidth, maxHeight int
+	maxPixels           int64
+	maxBytes            int64
+}
+
+// Default limits of HashReader, HashFile and HashBatch. They refuse the
+// decompression bombs whose decoding would take gigabytes of memory while
+// accepting the photos of any current camera. WithLimits and WithMaxBytes
+// replace them.
+const (
+	DefaultMaxPixels int64 = 1 << 27
+	DefaultMaxBytes  int64 = 1 << 28
+)
+
+// LimitError is returned when the dimensions declared by an image exceed
+// the limits set by WithLimits, or when its encoding exceeds the limit set
+// by WithMaxBytes. The image is not decoded.
+type LimitError struct {
+	// Width and Height are the dimensions of the image once oriented
+	// upright. They are zero when the bytes limit is exceeded.
+	Width, Height int
+	// Limit is the exceeded limit: "width", "height", "pixels" or "bytes".
+	Limit string
+}
+
+func (e *LimitError) Error() string {
+	if e.Limit == "bytes" {
+		return "goimagehash: image exceeds the bytes limit"
+	}
+	return fmt.Sprintf("goimagehash: image of %dx%d pixels exceeds the %s limit", e.Width, e.Height, e.Limit)
+}
+
+// WithLimits function returns a ReadOption refusing images whose declared
+// width, height or number of pixels exceed the limits with a *LimitError,
+// before any pixel is allocated. The width and the height are the ones of
+// the image once oriented upright by its EXIF orientation. The limits replace
+// the default ones, DefaultMaxPixels pixels and no width nor height limit.
+// Zero or negative limits are not enforced, so that WithLimits(0, 0, 0)
+// removes the default ones.
+func WithLimits(maxWidth, maxHeight int, maxPixels int64) ReadOption {
+	return func(o *readOptions) {
+		o.maxWidth = maxWidth
+		o.maxHeight = maxHeight
+		o.maxPixels = maxPixels
+	}
+}
+
+// WithMaxBytes function returns a ReadOption refusing images encoded in more
+// than maxBytes bytes with a *LimitError. No more than maxBytes + 1 bytes are
+// read. The limit replaces the default one, DefaultMaxBytes. A zero or
+// negative limit is not enforced.
+func WithMaxBytes(maxBytes int64) ReadOption {
+	return func(o *readOptions) {
+		o.maxBytes = maxBytes
+	}
+}
+
+// limited reports whether some limit is set.
+func (o *readOptions) limited() bool {
+	return o.maxWidth > 0 || o.maxHeight > 0 || o.maxPixels > 0
+}
+
+// checkLimits returns a *LimitError if config, oriented with the given EXIF
+// orientation, exceeds the limits.
+func (o *readOptions) checkLimits(config image.Config, orientation int) error {
+	if orientation >= 5 && orientation <= 8 {
+		// The orientations transposing the image swap its sides.
+		config.Width, config.Height = config.Height, config.Width
+	}
+	switch {
+	case o.maxWidth > 0 && config.Width > o.maxWidth:
+		return &LimitError{config.Width, config.Height, "width"}
+	case o.maxHeight > 0 && config.Height > o.maxHeight:
+		return &LimitError{config.Width, config.Height, "height"}
+	case o.maxPixels > 0 && int64(config.Width)*int64(config.Height) > o.maxPixels:
+		return &LimitError{config.Width, config.Height, "pixels"}
+	}
+	return nil
+}
+
+// WithReducedJPEG function returns a ReadOption decoding JPEG images at 1/2,
+// 1/4 or 1/8 of their size, the smallest which keeps both sides at least
+// minSize pixels. Only the low frequencies of the DCT blocks are decoded, so
+// that a large photo is hashed several times faster. The hashes differ
+// slightly from the hashes of the full size image, a minSize of at least
+// four times the hash width keeps them within a few bits.
+func WithReducedJPEG(minSize int) ReadOption {
+	return func(o *readOptions) {
+		o.reducedSize = minSize
+	}
+}
+
+// WithEXIFThumbnail function returns a ReadOption hashing the JPEG thumbnail
+// embedded in the EXIF data instead of the image when both its sides are at
+// least minSize pixels and its aspect ratio is the one of the image.
+// Thumbnails are often small and may not be updated by editors, so this
+// trades accuracy for speed.
+func WithEXIFThumbnail(minSize int) ReadOption {
+	return func(o *readOptions) {
+		o.thumbnailSize = minSize
+	}
+}
+
+// HashReader function decodes an image from r and returns its hash by hasher.
+// The format is detected by image.Decode, so any registered format is
+// supported. GIF, JPEG and PNG are registered by this package, other formats
+// need the blank import of their decoder, such as
+//
+//	import _ "golang.org/x/image/tiff"
+//
+// for TIFF images, without which they fail with image.ErrFormat. The EXIF
+// orientation of JPEG and TIFF images is applied before hashing, so that a
+// photo and its re-saved upright copy get the same hash.
+//
+// Images of more than DefaultMaxPixels pixels or DefaultMaxBytes bytes are
+// refused with a *LimitError before they are decoded, so that a small file
+// declaring huge dimensions can not exhaust the memory. WithLimits and
+// WithMaxBytes change these limits.
+func HashReader(r io.Reader, hasher Hasher, opts ...ReadOption) (Hash, error) {
+	img, err := newReadOptions(opts).read(r)
+	if err != nil {
+		return nil, err
+	}
+	return hasher.Hash(img)
+}
+
+func newReadOptions(opts []ReadOption) *readOptions {
+	o := &readOptions{maxPixels: DefaultMaxPixels, maxBytes: DefaultMaxBytes}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// read returns the image decoded from r, oriented upright.
+func (o *readOptions) read(r io.Reader) (image.Image, error) {
+	if o.maxBytes > 0 {
+		r = io.LimitReader(r, o.maxBytes+1)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if o.maxBytes > 0 && int64(len(data)) > o.maxBytes {
+		return nil, &LimitError{Limit: "bytes"}
+	}
+	orientation := exifOrientation(data)
+	img, err := o.decode(data, orientation)
+	if err != nil {
+		return nil, err
+	}
+	return transforms.Orient(img, orientation), nil
+}
+
+// HashFile function returns the hash by hasher of the image file at path,
+// see HashReader.
+func HashFile(path string, hasher Hasher, opts ...ReadOption) (Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return HashReader(f, hasher, opts...)
+}
+
+// decode returns the image encoded in data, of the given EXIF orientation.
+func (o *readOptions) decode(data []byte, orientation int) (image.Image, error) {
+	if o.limited() {
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if err := o.checkLimits(config, orientation); err != nil {
+			return nil, err
+		}
+	}
+	if (o.reducedSize <= 0 && o.thumbnailSize <= 0) || !bytes.HasPrefix(data, []byte("\xff\xd8")) {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		return img, err
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if o.thumbnailSize > 0 {
+		if img := o.thumbnail(data, config, orientation); img != nil {
+			return img, nil
+		}
+	}
+	if o.reducedSize > 0 {
+		for _, denom := range []int{8, 4, 2} {
+			if (config.Width+denom-1)/denom >= o.reducedSize && (config.Height+denom-1)/denom >= o.reducedSize {
+				return scaledjpeg.DecodeScaled(bytes.NewReader(data), denom)
+			}
+		}
+	}
+	return jpeg.Decode(bytes.NewReader(data))
+}
+
+// thumbnail returns the EXIF thumbnail of a JPEG image of the given config
+// and orientation when it is at least thumbnailSize pixels wide and high with
+// the same aspect ratio and within the limits.
+func (o *readOptions) thumbnail(data []byte, config image.Config, orientation int) image.Image {
+	thumb := exifThumbnail(data)
+	if thumb == nil {
+		return nil
+	}
+	c, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil || c.Width < o.thumbnailSize || c.Height < o.thumbnailSize || o.checkLimits(c, orientation) != nil {
+		return nil
+	}
+	// Allow one pixel of rounding on each side of the thumbnail.
+	if d := c.Width*config.Height - c.Height*config.Width; d > config.Width+config.Height || -d > config.Width+config.Height {
+		return nil
+	}
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		return nil
+	}
+	return img
+}
@@ -0,0 +1,266 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io/ioutil"
+	"testing"
+
+	"github.com/corona10/goimagehash/transforms"
+)
+
+func TestHashFile(t *testing.T) {
+	hasher := NewPerceptionHasher()
+	expected, err := hasher.Hash(loadImage(t, "_examples/sample1.jpg"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	hash, err := HashFile("_examples/sample1.jpg", hasher)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if hash.ToString() != expected.ToString() {
+		t.Errorf("HashFile is expected %s but got %s", expected.ToString(), hash.ToString())
+	}
+
+	if _, err := HashFile("_examples/missing.jpg", hasher); err == nil {
+		t.Errorf("HashFile of a missing file is expected to fail")
+	}
+	if _, err := HashReader(bytes.NewReader([]byte("not an image")), hasher); err == nil {
+		t.Errorf("HashReader of garbage is expected to fail")
+	}
+}
+
+func TestHashReaderOrientation(t *testing.T) {
+	src := loadImage(t, "_examples/sample1.jpg")
+	upright := image.NewRGBA(src.Bounds())
+	draw.Draw(upright, upright.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	hasher := NewPerceptionHasher()
+	expected, err := hasher.Hash(upright)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// inverse maps an orientation to the one storing an upright image with it.
+	inverse := [...]int{1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 8, 7: 7, 8: 6}
+	for orientation := 1; orientation <= 8; orientation++ {
+		stored := transforms.Orient(upright, inverse[orientation])
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, stored, &jpeg.Options{Quality: 95}); err != nil {
+			t.Fatalf("%s", err)
+		}
+		data := withExif(buf.Bytes(), exifTIFF(binary.BigEndian, orientation, nil))
+
+		hash, err := HashReader(bytes.NewReader(data), hasher)
+		if err != nil {
+			t.Errorf("orientation %d: %s", orientation, err)
+			continue
+		}
+		distance, err := expected.(*ImageHash).Distance(hash.(*ImageHash))
+		if err != nil {
+			t.Errorf("%s", err)
+		}
+		if distance > 2 {
+			t.Errorf("HashReader with orientation %d is expected to match the upright image but the distance is %d", orientation, distance)
+		}
+	}
+}
+
+func TestHashReaderUnregisteredFormat(t *testing.T) {
+	// No TIFF decoder is registered by the package nor by its tests.
+	data := exifTIFF(binary.LittleEndian, 6, nil)
+	if _, err := HashReader(bytes.NewReader(data), NewAverageHasher()); err != image.ErrFormat {
+		t.Errorf("HashReader of a TIFF image is expected %v but got %v", image.ErrFormat, err)
+	}
+}
+
+func TestHashReaderReducedJPEG(t *testing.T) {
+	for _, path := range []string{"_examples/sample2.jpg", "_examples/sample4.jpg"} {
+		for _, name := range []string{"ahash", "dhash", "phash", "ahash-256", "dhash-256", "phash-256"} {
+			hasher, err := LookupHasher(name)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			expected, err := HashFile(path, hasher)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			for _, minSize := range []int{64, 128, 256} {
+				hash, err := HashFile(path, hasher, WithReducedJPEG(minSize))
+				if err != nil {
+					t.Errorf("%s: %s", path, err)
+					continue
+				}
+				// dhash-256 of sample4 already moves by about 20 bits when the
+				// full decode is exactly box downscaled by 2, 4 or 8.
+				if distance := hashDistance(t, expected, hash); distance > hash.Bits()/8 {
+					t.Errorf("%s of %s decoded with WithReducedJPEG(%d) is %d bits away from the full decode", name, path, minSize, distance)
+				}
+			}
+		}
+	}
+}
+
+// hashDistance returns the distance between two hashes of the same type.
+func hashDistance(t *testing.T, h1, h2 Hash) int {
+	var distance int
+	var err error
+	switch h := h1.(type) {
+	case *ImageHash:
+		distance, err = h.Distance(h2.(*ImageHash))
+	case *ExtImageHash:
+		distance, err = h.Distance(h2.(*ExtImageHash))
+	}
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+	return distance
+}
+
+func TestHashReaderEXIFThumbnail(t *testing.T) {
+	src := loadImage(t, "_examples/sample1.jpg")
+	var main bytes.Buffer
+	if err := jpeg.Encode(&main, src, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	// The thumbnail is mirrored so that its hash is told apart.
+	b := src.Bounds()
+	encodeThumbnail := func(width, height int) []byte {
+		thumb := image.NewGray(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				thumb.Set(width-1-x, y, src.At(b.Min.X+x*b.Dx()/width, b.Min.Y+y*b.Dy()/height))
+			}
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, thumb, nil); err != nil {
+			t.Fatalf("%s", err)
+		}
+		return buf.Bytes()
+	}
+	square := encodeThumbnail(128, 128)
+	wide := encodeThumbnail(160, 120)
+
+	hasher := NewDifferenceHasher()
+	mainHash, err := HashReader(bytes.NewReader(main.Bytes()), hasher)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	thumbHash, err := HashReader(bytes.NewReader(square), hasher)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if thumbHash.ToString() == mainHash.ToString() {
+		t.Fatalf("the thumbnail is expected to have another hash than the image")
+	}
+
+	for _, tt := range []struct {
+		name      string
+		thumbnail []byte
+		minSize   int
+		expected  Hash
+	}{
+		{"large enough thumbnail", square, 64, thumbHash},
+		{"too small thumbnail", square, 200, mainHash},
+		{"other aspect ratio", wide, 64, mainHash},
+	} {
+		data := withExif(main.Bytes(), exifTIFF(binary.LittleEndian, 1, tt.thumbnail))
+		hash, err := HashReader(bytes.NewReader(data), hasher, WithEXIFThumbnail(tt.minSize))
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if hash.ToString() != tt.expected.ToString() {
+			t.Errorf("HashReader with the %s is expected %s but got %s", tt.name, tt.expected.ToString(), hash.ToString())
+		}
+	}
+}
+
+func BenchmarkHashFile(b *testing.B) {
+	hasher := NewPerceptionHasher()
+	for i := 0; i < b.N; i++ {
+		if _, err := HashFile("_examples/sample2.jpg", hasher); err != nil {
+			b.Fatalf("%s", err)
+		}
+	}
+}
+
+func BenchmarkHashFileReducedJPEG(b *testing.B) {
+	hasher := NewPerceptionHasher()
+	for i := 0; i < b.N; i++ {
+		if _, err := HashFile("_examples/sample2.jpg", hasher, WithReducedJPEG(64)); err != nil {
+			b.Fatalf("%s", err)
+		}
+	}
+}
+
+// pngHeader returns the beginning of a gray PNG image declaring width x height
+// pixels, which is enough for image.DecodeConfig.
+func pngHeader(width, height uint32) []byte {
+	var b bytes.Buffer
+	b.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	ihdr[12] = 8 // bit depth, gray color type, deflate, no filter and no interlace.
+	binary.Write(&b, binary.BigEndian, uint32(13))
+	b.Write(ihdr)
+	binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+	return b.Bytes()
+}
+
+func TestHashReaderLimits(t *testing.T) {
+	sample, err := ioutil.ReadFile("_examples/sample4.jpg")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	// The dimensions fit the int of 32 bits platforms, eight bytes per pixel.
+	bomb := pngHeader(16000, 16000)
+	// sample4 is 904x680, stored rotated it is 680 pixels wide once upright.
+	rotated := withExif(sample, exifTIFF(binary.BigEndian, 6, nil))
+	hasher := NewAverageHasher()
+
+	for _, tt := range []struct {
+		name  string
+		data  []byte
+		opts  []ReadOption
+		limit string
+	}{
+		{"bomb", bomb, nil, "pixels"},
+		{"bomb pixels", bomb, []ReadOption{WithLimits(0, 0, 1<<24)}, "pixels"},
+		{"bomb width", bomb, []ReadOption{WithLimits(10000, 0, 0)}, "width"},
+		{"bomb height", bomb, []ReadOption{WithLimits(0, 10000, 0)}, "height"},
+		{"sample4 width", sample, []ReadOption{WithLimits(903, 0, 0)}, "width"},
+		{"sample4 height", sample, []ReadOption{WithLimits(0, 679, 0)}, "height"},
+		{"sample4 pixels", sample, []ReadOption{WithLimits(0, 0, 904*680-1), WithReducedJPEG(64)}, "pixels"},
+		{"sample4", sample, []ReadOption{WithLimits(904, 680, 904*680)}, ""},
+		{"sample4 reduced", sample, []ReadOption{WithLimits(904, 680, 904*680), WithReducedJPEG(64)}, ""},
+		{"sample4 bytes", sample, []ReadOption{WithMaxBytes(int64(len(sample)) - 1)}, "bytes"},
+		{"sample4 exact bytes", sample, []ReadOption{WithMaxBytes(int64(len(sample)))}, ""},
+		{"sample4 default limits", sample, nil, ""},
+		{"rotated width", rotated, []ReadOption{WithLimits(679, 0, 0)}, "width"},
+		{"rotated height", rotated, []ReadOption{WithLimits(0, 903, 0)}, "height"},
+		{"rotated", rotated, []ReadOption{WithLimits(680, 904, 0)}, ""},
+	} {
+		_, err := HashReader(bytes.NewReader(tt.data), hasher, tt.opts...)
+		if tt.limit == "" {
+			if err != nil {
+				t.Errorf("%s: %s", tt.name, err)
+			}
+			continue
+		}
+		if e, ok := err.(*LimitError); !ok || e.Limit != tt.limit {
+			t.Errorf("%s is expected to exceed the %s limit but got %v", tt.name, tt.limit, err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"errors"
+	"image"
+
+	"github.com/corona10/goimagehash/etcs"
+	"github.com/corona10/goimagehash/transforms"
+)
+
+// ReusableHasher is a Hasher of extended hashes which owns the buffers of the
+// computation and reuses them from one image to the next. Once an image of a
+// given size is hashed, HashInto does not allocate for images of the same
+// size and of the standard image types, unless WithTrim, WithPythonCompat or
+// WithFixedPoint is given or a perception hash is larger than 512 bits.
+// A ReusableHasher should not be used by several goroutines at once.
+type ReusableHasher struct {
+	kind          Kind
+	width, height int
+	opts          *options
+	fn            func(img image.Image, width, height int, o *options) (*ExtImageHash, error)
+
+	scaler transforms.Scaler
+	// pixels holds the resized gray scale image and rows its rows.
+	pixels []float64
+	rows   [][]float64
+	// coeffs holds the DCT coefficients, flattens the values compared to
+	// their mean or median and sorted the values reordered by the median.
+	coeffs   []float64
+	flattens []float64
+	sorted   []float64
+	bits     []uint64
+}
+
+// NewReusableAverageHasher function returns a ReusableHasher computing
+// ExtAverageHash of the given size configured by opts.
+func NewReusableAverageHasher(width, height int, opts ...Option) *ReusableHasher {
+	return newReusableHasher(AHash, width, height, width, height, opts, extAverageHash)
+}
+
+// NewReusableDifferenceHasher function returns a ReusableHasher computing
+// ExtDifferenceHash of the given size configured by opts.
+func NewReusableDifferenceHasher(width, height int, opts ...Option) *ReusableHasher {
+	return newReusableHasher(DHash, width, height, width+1, height, opts, extDifferenceHash)
+}
+
+// NewReusablePerceptionHasher function returns a ReusableHasher computing
+// ExtPerceptionHash of the given size configured by opts.
+func NewReusablePerceptionHasher(width, height int, opts ...Option) *ReusableHasher {
+	return newReusableHasher(PHash, width, height, width*height, width*height, opts, extPerceptionHash)
+}
+
+// newReusableHasher returns a ReusableHasher resizing images to grayWidth x grayHeight.
+func newReusableHasher(kind Kind, width, height, grayWidth, grayHeight int, opts []Option,
+	fn func(img image.Image, width, height int, o *options) (*ExtImageHash, error)) *ReusableHasher {
+	h := &ReusableHasher{kind: kind, width: width, height: height, opts: newOptions(opts), fn: fn}
+	if width <= 0 || height <= 0 {
+		return h
+	}
+	h.scaler.Resampler = h.opts.resampler()
+	h.pixels = make([]float64, grayWidth*grayHeight)
+	h.rows = grayRows(h.pixels, grayWidth, grayHeight)
+	h.coeffs = make([]float64, width*height)
+	h.flattens = make([]float64, width*height)
+	h.sorted = make([]float64, width*height)
+	h.bits = make([]uint64, (width*height+63)/64)
+	return h
+}
+
+// clone returns a ReusableHasher computing the same hashes as h with its
+// own buffers.
+func (h *ReusableHasher) clone() *ReusableHasher {
+	c := &ReusableHasher{kind: h.kind, width: h.width, height: h.height, opts: h.opts, fn: h.fn}
+	c.scaler.Resampler = h.scaler.Resampler
+	if len(h.rows) == 0 {
+		return c
+	}
+	c.pixels = make([]float64, len(h.pixels))
+	c.rows = grayRows(c.pixels, len(h.pixels)/len(h.rows), len(h.rows))
+	c.coeffs = make([]float64, len(h.coeffs))
+	c.flattens = make([]float64, len(h.flattens))
+	c.sorted = make([]float64, len(h.sorted))
+	c.bits = make([]uint64, len(h.bits))
+	return c
+}
+
+// Kind method returns the kind of the hashes created by Hash.
+func (h *ReusableHasher) Kind() Kind {
+	return h.kind
+}
+
+// Hash method returns a hash computation of img in a new *ExtImageHash.
+func (h *ReusableHasher) Hash(img image.Image) (Hash, error) {
+	dst := &ExtImageHash{}
+	if err := h.HashInto(img, dst); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// HashInto method writes the hash of img into dst, reusing the storage of
+// dst when it is large enough. dst is left unchanged on errors.
+func (h *ReusableHasher) HashInto(img image.Image, dst *ExtImageHash) error {
+	o := h.opts
+	if err := o.canceled(); err != nil {
+		return err
+	}
+	if dst == nil {
+		return errors.New("destination hash can not be nil")
+	}
+	if o.pythonCompat || o.fixedPoint {
+		hash, err := h.fn(img, h.width, h.height, o)
+		if err != nil {
+			return err
+		}
+		*dst = *hash
+		return nil
+	}
+	img = o.prepare(img)
+	if img == nil {
+		return errors.New("image object can not be nil")
+	}
+	if h.width <= 0 || h.height <= 0 {
+		return errors.New("width and height should be positive")
+	}
+
+	for i := range h.bits {
+		h.bits[i] = 0
+	}
+	var err error
+	switch h.kind {
+	case AHash:
+		err = h.average(img)
+	case DHash:
+		err = h.difference(img)
+	default:
+		err = h.perception(img)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cap(dst.hash) < len(h.bits) {
+		dst.hash = make([]uint64, len(h.bits))
+	}
+	dst.hash = dst.hash[:len(h.bits)]
+	copy(dst.hash, h.bits)
+	dst.kind = h.kind
+	dst.bits = h.width * h.height
+	return nil
+}
+
+func (h *ReusableHasher) average(img image.Image) error {
+	if err := h.scaler.Resample(img, h.pixels, h.width, h.height); err != nil {
+		return h.opts.failure(err)
+	}
+	flattens := flattenInto(h.flattens, h.pixels, h.width, h.width, h.height)
+	putBitsAbove(h.bits, flattens, etcs.MeanOfPixels(flattens))
+	return nil
+}
+
+func (h *ReusableHasher) difference(img image.Image) error {
+	if err := h.scaler.Resample(img, h.pixels, h.width+1, h.height); err != nil {
+		return h.opts.failure(err)
+	}
+	putDifferenceBits(h.bits, h.rows)
+	return nil
+}
+
+func (h *ReusableHasher) perception(img image.Image) error {
+	imgSize := h.width * h.height
+	if imgSize&(imgSize-1) != 0 {
+		return errors.New("width * height should be power of 2")
+	}
+	if err := h.scaler.Resample(img, h.pixels, imgSize, imgSize); err != nil {
+		return h.opts.failure(err)
+	}
+	var flattens []float64
+	if transforms.HasDCT2DFast(imgSize) {
+		if err := transforms.DCT2DFast(h.pixels, imgSize, h.width, h.height, h.coeffs); err != nil {
+			return err
+		}
+		flattens = h.coeffs
+		if h.width != h.height {
+			flattens = flattenInto(h.flattens, h.coeffs, h.width, h.width, h.height)
+		}
+	} else {
+		dct := transforms.DCT{Workers: h.opts.parallelism, Done: h.opts.done}
+		if err := dct.Transform2D(h.rows, imgSize, imgSize); err != nil {
+			return h.opts.failure(err)
+		}
+		flattens = flattenInto(h.flattens, h.pixels, imgSize, h.width, h.height)
+	}
+	copy(h.sorted, flattens)
+	putBitsAbove(h.bits, flattens, etcs.MedianOfPixelsInPlace(h.sorted))
+	return nil
+}
+
+// flattenInto orders the width x height values of pixels, stored row by row
+// every stride values, as transforms.FlattenPixels does into dst.
+func flattenInto(dst, pixels []float64, stride, width, height int) []float64 {
+	for i := range dst {
+		dst[i] = 0
+	}
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			dst[height*i+j] = pixels[i*stride+j]
+		}
+	}
+	return dst
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goimagehash
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestReusableHasher(t *testing.T) {
+	images := []image.Image{
+		loadImage(t, "_examples/sample1.jpg"),
+		loadImage(t, "_examples/sample4.jpg"),
+		loadImage(t, "_examples/sample1.jpg"),
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{WithInterpolation(Bicubic), WithLuma(Rec709)},
+		{WithTrim(nil, 8)},
+		{WithFixedPoint()},
+	} {
+		for _, tt := range []struct {
+			reusable *ReusableHasher
+			name     string
+		}{
+			{NewReusableAverageHasher(8, 8, opts...), "ahash-8x8"},
+			{NewReusableAverageHasher(16, 8, opts...), "ahash-16x8"},
+			{NewReusableDifferenceHasher(16, 16, opts...), "dhash-16x16"},
+			{NewReusableDifferenceHasher(8, 4, opts...), "dhash-8x4"},
+			{NewReusablePerceptionHasher(8, 8, opts...), "phash-8x8"},
+			{NewReusablePerceptionHasher(16, 8, opts...), "phash-16x8"},
+			{NewReusablePerceptionHasher(32, 32, opts...), "phash-32x32"},
+		} {
+			hasher, err := LookupHasher(tt.name, opts...)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			dst := &ExtImageHash{}
+			for _, img := range images {
+				expected, err := hasher.Hash(img)
+				if err != nil {
+					t.Fatalf("%s", err)
+				}
+				if err := tt.reusable.HashInto(img, dst); err != nil {
+					t.Errorf("%s: %v", tt.name, err)
+					continue
+				}
+				if dst.ToString() != expected.ToString() || dst.Bits() != expected.Bits() {
+					t.Errorf("%s is expected %v but got %v", tt.name, expected.ToString(), dst.ToString())
+				}
+			}
+			if tt.reusable.Kind() != hasher.Kind() {
+				t.Errorf("%s kind is expected %v but got %v", tt.name, hasher.Kind(), tt.reusable.Kind())
+			}
+		}
+	}
+
+	img := images[0]
+	for _, tt := range []struct {
+		hasher *ReusableHasher
+		img    image.Image
+		dst    *ExtImageHash
+	}{
+		{NewReusableAverageHasher(8, 8), nil, &ExtImageHash{}},
+		{NewReusableAverageHasher(8, 8), img, nil},
+		{NewReusableAverageHasher(0, 8), img, &ExtImageHash{}},
+		{NewReusablePerceptionHasher(6, 6), img, &ExtImageHash{}},
+	} {
+		if err := tt.hasher.HashInto(tt.img, tt.dst); err == nil {
+			t.Errorf("HashInto is expected to fail")
+		}
+	}
+}
+
+func TestReusableHasherAllocs(t *testing.T) {
+	src := loadImage(t, "_examples/sample1.jpg")
+	rgba := image.NewRGBA(src.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+	for _, hasher := range []*ReusableHasher{
+		NewReusableAverageHasher(16, 16),
+		NewReusableDifferenceHasher(16, 16),
+		NewReusablePerceptionHasher(8, 8),
+		NewReusablePerceptionHasher(16, 16),
+		NewReusablePerceptionHasher(16, 8, WithInterpolation(Lanczos3)),
+	} {
+		for _, img := range []image.Image{src, rgba} {
+			dst := &ExtImageHash{}
+			if err := hasher.HashInto(img, dst); err != nil {
+				t.Fatalf("%s", err)
+			}
+			words := &dst.hash[0]
+			allocs := testing.AllocsPerRun(5, func() {
+				hasher.HashInto(img, dst)
+			})
+			if allocs != 0 {
+				t.Errorf("HashInto of %v %dx%d of %T is expected not to allocate but allocates %v times", hasher.kind, hasher.width, hasher.height, img, allocs)
+			}
+			if &dst.hash[0] != words {
+				t.Errorf("HashInto is expected to reuse the storage of the hash")
+			}
+		}
+	}
+}
+
+func BenchmarkReusablePerceptionHasher(b *testing.B) {
+	img := loadImage(b, "_examples/sample1.jpg")
+	hasher := NewReusablePerceptionHasher(16, 16)
+	dst := &ExtImageHash{}
+	hasher.HashInto(img, dst)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hasher.HashInto(img, dst)
+	}
+}
+
+func BenchmarkExtPerceptionHasher(b *testing.B) {
+	img := loadImage(b, "_examples/sample1.jpg")
+	hasher := NewExtPerceptionHasher(16, 16)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hasher.Hash(img)
+	}
+}
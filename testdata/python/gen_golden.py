@@ -0,0 +1,32 @@
+#!/usr/bin/env python3
+# Regenerates golden.txt from the Python imagehash library:
+#
+#     pip install imagehash
+#     python3 gen_golden.py > golden.txt
+#
+# Each line holds an image, an imagehash function, its hash_size and the
+# resulting hex string. python_test.go checks that goimagehash reproduces them.
+import imagehash
+from PIL import Image
+
+CASES = [
+    ("rgb8x8.png", "average_hash", 8),
+    ("rgb8x8.png", "whash", 8),
+    ("rgb9x8.png", "dhash", 8),
+    ("rgb16x16.png", "average_hash", 16),
+    ("rgb16x16.png", "whash", 8),
+    ("rgb32x32.png", "phash", 8),
+    # Crops of the photos of _examples, which Pillow has to downscale.
+    ("sample1_131x97.png", "average_hash", 8),
+    ("sample1_131x97.png", "dhash", 8),
+    ("sample1_131x97.png", "phash", 8),
+    ("sample1_131x97.png", "whash", 8),
+    ("sample4_203x150.png", "average_hash", 16),
+    ("sample4_203x150.png", "dhash", 16),
+    ("sample4_203x150.png", "phash", 16),
+    ("sample4_203x150.png", "whash", 16),
+]
+
+for name, func, hash_size in CASES:
+    image = Image.open(name)
+    print(name, func, hash_size, getattr(imagehash, func)(image, hash_size=hash_size))
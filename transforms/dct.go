@@ -5,7 +5,9 @@
 package transforms
 
 import (
+	"fmt"
 	"math"
+	"runtime"
 	"sync"
 )
 
@@ -23,10 +25,11 @@ func forwardTransform(input, temp []float64, Len int) {
 	}
 
 	halfLen := Len / 2
+	cos := cosTable(Len)
 	for i := 0; i < halfLen; i++ {
 		x, y := input[i], input[Len-1-i]
 		temp[i] = x + y
-		temp[i+halfLen] = (x - y) / (math.Cos((float64(i)+0.5)*math.Pi/float64(Len)) * 2)
+		temp[i+halfLen] = (x - y) / cos[i]
 	}
 	forwardTransform(temp, input, halfLen)
 	forwardTransform(temp[halfLen:], input, halfLen)
@@ -37,43 +40,147 @@ func forwardTransform(input, temp []float64, Len int) {
 	input[Len-2], input[Len-1] = temp[halfLen-1], temp[Len-1]
 }
 
-// DCT2D function returns a  result of DCT2D by using the separable property.
-func DCT2D(input [][]float64, w int, h int) [][]float64 {
-	output := make([][]float64, h)
-	for i := range output {
-		output[i] = make([]float64, w)
+// cosTables caches the divisors of forwardTransform by the log2 of the
+// length, for the powers of 2 only.
+var cosTables [32]struct {
+	once  sync.Once
+	table []float64
+}
+
+// cosTable returns the divisors math.Cos((i+0.5)*π/Len)*2 of forwardTransform.
+// They are computed on each call when Len is not a power of 2.
+func cosTable(Len int) []float64 {
+	if Len&(Len-1) != 0 {
+		return newCosTable(Len)
 	}
+	n := 0
+	for 1<<uint(n) < Len {
+		n++
+	}
+	c := &cosTables[n]
+	c.once.Do(func() {
+		c.table = newCosTable(Len)
+	})
+	return c.table
+}
 
-	wg := new(sync.WaitGroup)
+func newCosTable(Len int) []float64 {
+	table := make([]float64, Len/2)
+	for i := range table {
+		table[i] = math.Cos((float64(i)+0.5)*math.Pi/float64(Len)) * 2
+	}
+	return table
+}
+
+// DCT computes two-dimensional DCT-II with a bounded number of goroutines.
+// The zero value uses runtime.GOMAXPROCS(0) goroutines and never cancels.
+type DCT struct {
+	// Workers is the maximum number of goroutines transforming rows and
+	// columns concurrently. 1 transforms on the calling goroutine only,
+	// 0 or less uses runtime.GOMAXPROCS(0).
+	Workers int
+	// Done, if not nil, aborts the transform with ErrCanceled once closed.
+	Done <-chan struct{}
+}
+
+// Transform2D method replaces the h rows of w values of input by their DCT2D,
+// computed by using the separable property. w and h should be powers of 2.
+// Besides one row and one column per worker, nothing is allocated.
+func (d DCT) Transform2D(input [][]float64, w, h int) error {
+	if w <= 0 || h <= 0 || w&(w-1) != 0 || h&(h-1) != 0 {
+		return fmt.Errorf("DCT2D of %dx%d values: sizes should be powers of 2", w, h)
+	}
+	if len(input) < h {
+		return fmt.Errorf("DCT2D of %dx%d values: input holds %d rows", w, h, len(input))
+	}
 	for i := 0; i < h; i++ {
-		wg.Add(1)
-		go func(i int) {
-			cols := DCT1D(input[i])
-			output[i] = cols
-			wg.Done()
-		}(i)
+		if len(input[i]) < w {
+			return fmt.Errorf("DCT2D of %dx%d values: row %d holds %d values", w, h, i, len(input[i]))
+		}
 	}
 
-	wg.Wait()
-	for i := 0; i < w; i++ {
-		wg.Add(1)
-		in := make([]float64, h)
-		go func(i int) {
-			for j := 0; j < h; j++ {
-				in[j] = output[j][i]
-			}
-			rows := DCT1D(in)
-			for j := 0; j < len(rows); j++ {
-				output[j][i] = rows[j]
+	workers := d.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	d.parallel(workers, h, w, func(i int, temp []float64) {
+		transform1D(input[i][:w], temp)
+	})
+	if canceled(d.Done) {
+		return ErrCanceled
+	}
+	d.parallel(workers, w, h, func(i int, column []float64) {
+		temp := column[h:]
+		column = column[:h]
+		for j := range column {
+			column[j] = input[j][i]
+		}
+		transform1D(column, temp)
+		for j, v := range column {
+			input[j][i] = v
+		}
+	})
+	if canceled(d.Done) {
+		return ErrCanceled
+	}
+	return nil
+}
+
+// parallel calls fn for the n indices on at most workers goroutines, each with
+// its own buffer of 2 * size values. It stops early once d.Done is closed.
+func (d DCT) parallel(workers, n, size int, fn func(i int, buf []float64)) {
+	if workers <= 1 || n == 1 {
+		buf := make([]float64, 2*size)
+		for i := 0; i < n && !canceled(d.Done); i++ {
+			fn(i, buf)
+		}
+		return
+	}
+	if workers > n {
+		workers = n
+	}
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	for k := 0; k < workers; k++ {
+		go func(k int) {
+			buf := make([]float64, 2*size)
+			for i := k; i < n && !canceled(d.Done); i += workers {
+				fn(i, buf)
 			}
 			wg.Done()
-		}(i)
+		}(k)
 	}
-
 	wg.Wait()
+}
+
+// transform1D computes the DCT-II of input in place, with the static tables when
+// they exist. temp should hold len(input) values.
+func transform1D(input, temp []float64) {
+	if HasDCT2DFast(len(input)) {
+		forwardDCT(input)
+		return
+	}
+	forwardTransform(input, temp, len(input))
+}
+
+// DCT2D function returns a  result of DCT2D by using the separable property.
+// w and h should be powers of 2, otherwise nil is returned. The rows of input are replaced by the result, which is computed on up to
+// runtime.GOMAXPROCS(0) goroutines. Use DCT to bound them.
+func DCT2D(input [][]float64, w int, h int) [][]float64 {
+	output, _ := DCT2DDone(input, w, h, nil)
 	return output
 }
 
+// DCT2DDone function returns a result of DCT2D like DCT2D but gives up with
+// ErrCanceled once done is closed. A nil done never cancels.
+func DCT2DDone(input [][]float64, w int, h int, done <-chan struct{}) ([][]float64, error) {
+	if err := (DCT{Done: done}).Transform2D(input, w, h); err != nil {
+		return nil, err
+	}
+	return input[:h], nil
+}
+
 // DCT2DFast64 function returns a result of DCT2D by using the separable property.
 // Fast uses static DCT tables for improved performance. Returns flattened pixels.
 func DCT2DFast64(input *[]float64) (flattens [64]float64) {
@@ -121,3 +228,62 @@ func DCT2DFast256(input *[]float64) (flattens [256]float64) {
 	}
 	return flattens
 }
+
+// forwardDCT runs the static DCT of the size of input, which should be a power of 2 from 4 to 512.
+func forwardDCT(input []float64) {
+	switch len(input) {
+	case 4:
+		forwardDCT4(input)
+	case 8:
+		forwardDCT8(input)
+	case 16:
+		forwardDCT16(input)
+	case 32:
+		forwardDCT32(input)
+	case 64:
+		forwardDCT64(input)
+	case 128:
+		forwardDCT128(input)
+	case 256:
+		forwardDCT256(input)
+	case 512:
+		forwardDCT512(input)
+	}
+}
+
+// HasDCT2DFast function reports whether DCT2DFast supports size.
+func HasDCT2DFast(size int) bool {
+	return size >= 4 && size <= 512 && size&(size-1) == 0
+}
+
+// DCT2DFast function computes the DCT2D of the size x size input stored row
+// by row and writes its top-left width x height coefficients row by row into
+// output. The result is identical to DCT2D but uses static DCT tables and does
+// not allocate. input is overwritten. Sizes are powers of 2 from 4 to 512.
+func DCT2DFast(input []float64, size, width, height int, output []float64) error {
+	if !HasDCT2DFast(size) {
+		return fmt.Errorf("no static DCT of size %d", size)
+	}
+	if len(input) < size*size {
+		return fmt.Errorf("input should hold %d values but holds %d", size*size, len(input))
+	}
+	if width <= 0 || height <= 0 || width > size || height > size || len(output) < width*height {
+		return fmt.Errorf("output of %dx%d coefficients does not fit a DCT of size %d", width, height, size)
+	}
+
+	for i := 0; i < size; i++ { // height
+		forwardDCT(input[i*size : (i+1)*size])
+	}
+
+	var column [512]float64
+	for i := 0; i < width; i++ { // width
+		for j := 0; j < size; j++ {
+			column[j] = input[size*j+i]
+		}
+		forwardDCT(column[:size])
+		for j := 0; j < height; j++ {
+			output[width*j+i] = column[j]
+		}
+	}
+	return nil
+}
@@ -5,6 +5,8 @@
 package transforms
 
 import (
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -39,6 +41,33 @@ func TestDCT1D(t *testing.T) {
 	}
 }
 
+// TestDCT1DNonPowerOf2 checks that a length which is not a power of 2 does
+// not spoil the cosines cached for the next power of 2.
+func TestDCT1DNonPowerOf2(t *testing.T) {
+	for _, lens := range [][2]int{{6, 8}, {12, 16}} {
+		DCT1D(make([]float64, lens[0]))
+
+		n := lens[1]
+		input := make([]float64, n)
+		for i := range input {
+			input[i] = float64(i*i%7) + 1
+		}
+		expected := make([]float64, n)
+		for k := range expected {
+			for i, v := range input {
+				expected[k] += v * math.Cos(math.Pi/float64(n)*(float64(i)+0.5)*float64(k))
+			}
+		}
+		out := DCT1D(input)
+		for k := range out {
+			if math.Abs(out[k]-expected[k]) > 1e-9 {
+				t.Errorf("DCT1D of length %d after length %d is expected %v but got %v", n, lens[0], expected, out)
+				break
+			}
+		}
+	}
+}
+
 func TestDCT2D(t *testing.T) {
 	for _, tt := range []struct {
 		input  [][]float64
@@ -72,3 +101,202 @@ func TestDCT2D(t *testing.T) {
 		}
 	}
 }
+
+func TestDCT2DDone(t *testing.T) {
+	input := [][]float64{{1, 2}, {3, 4}}
+	out, err := DCT2DDone(input, 2, 2, nil)
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+	if len(out) != 2 || out[0][0] != 10 {
+		t.Errorf("DCT2DDone(%v) is expected to start with 10 but got %v", input, out)
+	}
+
+	done := make(chan struct{})
+	close(done)
+	if _, err := DCT2DDone([][]float64{{1, 2}, {3, 4}}, 2, 2, done); err != ErrCanceled {
+		t.Errorf("DCT2DDone with a closed channel is expected %v but got %v", ErrCanceled, err)
+	}
+}
+
+// randomPixels returns size x size reproducible gray levels.
+func randomPixels(size int) []float64 {
+	return randomValues(size * size)
+}
+
+// randomValues returns n reproducible gray levels.
+func randomValues(n int) []float64 {
+	r := rand.New(rand.NewSource(int64(n)))
+	pixels := make([]float64, n)
+	for i := range pixels {
+		pixels[i] = float64(r.Intn(256))
+	}
+	return pixels
+}
+
+func TestDCT2DFast(t *testing.T) {
+	for size := 4; size <= 512; size *= 2 {
+		for _, dims := range [][2]int{{size / 4, size / 4}, {size / 2, size / 4}, {size, 1}} {
+			width, height := dims[0], dims[1]
+			flat := randomPixels(size)
+			input := make([][]float64, size)
+			for i := range input {
+				input[i] = append([]float64{}, flat[i*size:(i+1)*size]...)
+			}
+			expected := DCT2D(input, size, size)
+
+			output := make([]float64, width*height)
+			if err := DCT2DFast(flat, size, width, height, output); err != nil {
+				t.Fatalf("%s", err)
+			}
+			for i, v := range output {
+				if v != expected[i/width][i%width] {
+					t.Errorf("DCT2DFast of size %d is expected %v at (%d, %d) but got %v", size, expected[i/width][i%width], i%width, i/width, v)
+					break
+				}
+			}
+		}
+	}
+
+	output := make([]float64, 64)
+	for _, tt := range []struct {
+		input               []float64
+		size, width, height int
+	}{
+		{make([]float64, 1024*1024), 1024, 8, 8},
+		{make([]float64, 63), 8, 8, 8},
+		{make([]float64, 64*64), 64, 16, 16},
+		{make([]float64, 4*4), 4, 8, 8},
+	} {
+		if err := DCT2DFast(tt.input, tt.size, tt.width, tt.height, output); err == nil {
+			t.Errorf("DCT2DFast(%d, %d, %d) is expected to fail", tt.size, tt.width, tt.height)
+		}
+	}
+}
+
+// dct2DReference returns the DCT2D of the w x h pixels by transforming
+// copies of each row then of each column with DCT1D.
+func dct2DReference(pixels []float64, w, h int) [][]float64 {
+	output := make([][]float64, h)
+	for i := range output {
+		output[i] = DCT1D(append([]float64{}, pixels[i*w:(i+1)*w]...))
+	}
+	column := make([]float64, h)
+	for i := 0; i < w; i++ {
+		for j := range column {
+			column[j] = output[j][i]
+		}
+		DCT1D(column)
+		for j, v := range column {
+			output[j][i] = v
+		}
+	}
+	return output
+}
+
+func TestDCTTransform2D(t *testing.T) {
+	for _, dims := range [][2]int{{1, 1}, {2, 1}, {1, 8}, {4, 4}, {16, 32}, {64, 8}, {1024, 4}, {8, 2048}} {
+		w, h := dims[0], dims[1]
+		pixels := randomValues(w * h)
+		expected := dct2DReference(pixels, w, h)
+		for _, workers := range []int{1, 2, 3, 8, 0} {
+			input := make([][]float64, h)
+			for i := range input {
+				input[i] = append([]float64{}, pixels[i*w:(i+1)*w]...)
+			}
+			if err := (DCT{Workers: workers}).Transform2D(input, w, h); err != nil {
+				t.Errorf("%s", err)
+				continue
+			}
+		compare:
+			for i := range input {
+				for j, v := range input[i] {
+					if v != expected[i][j] {
+						t.Errorf("DCT2D of %dx%d with %d workers is expected %v at (%d, %d) but got %v", w, h, workers, expected[i][j], j, i, v)
+						break compare
+					}
+				}
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		input [][]float64
+		w, h  int
+	}{
+		{[][]float64{{1, 2, 3}, {4, 5, 6}}, 3, 2},
+		{[][]float64{{1, 2}, {3, 4}}, 2, 4},
+		{[][]float64{{1, 2}, {3}}, 2, 2},
+		{nil, 0, 0},
+	} {
+		if err := (DCT{}).Transform2D(tt.input, tt.w, tt.h); err == nil {
+			t.Errorf("DCT2D of %dx%d values in %v is expected to fail", tt.w, tt.h, tt.input)
+		}
+	}
+
+	done := make(chan struct{})
+	close(done)
+	for _, workers := range []int{1, 4} {
+		input := [][]float64{{1, 2, 3, 4}, {5, 6, 7, 8}}
+		if err := (DCT{Workers: workers, Done: done}).Transform2D(input, 4, 2); err != ErrCanceled {
+			t.Errorf("DCT2D with %d workers and a closed channel is expected %v but got %v", workers, ErrCanceled, err)
+		}
+	}
+}
+
+func benchmarkDCT2D(b *testing.B, size int) {
+	flat := randomPixels(size)
+	input := make([][]float64, size)
+	for i := 0; i < b.N; i++ {
+		for j := range input {
+			input[j] = append(input[j][:0], flat[j*size:(j+1)*size]...)
+		}
+		DCT2D(input, size, size)
+	}
+}
+
+func benchmarkDCTTransform2D(b *testing.B, workers, w, h int) {
+	pixels := randomValues(w * h)
+	input := make([][]float64, h)
+	for j := range input {
+		input[j] = make([]float64, w)
+	}
+	dct := DCT{Workers: workers}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range input {
+			copy(input[j], pixels[j*w:(j+1)*w])
+		}
+		dct.Transform2D(input, w, h)
+	}
+}
+
+func benchmarkDCT2DFast(b *testing.B, size int) {
+	flat := randomPixels(size)
+	input := make([]float64, size*size)
+	output := make([]float64, size/8*size/8)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		copy(input, flat)
+		DCT2DFast(input, size, size/8, size/8, output)
+	}
+}
+
+func BenchmarkDCT2D32(b *testing.B)      { benchmarkDCT2D(b, 32) }
+func BenchmarkDCT2DFast32(b *testing.B)  { benchmarkDCT2DFast(b, 32) }
+func BenchmarkDCT2D128(b *testing.B)     { benchmarkDCT2D(b, 128) }
+func BenchmarkDCT2DFast128(b *testing.B) { benchmarkDCT2DFast(b, 128) }
+func BenchmarkDCT2D256(b *testing.B)     { benchmarkDCT2D(b, 256) }
+func BenchmarkDCT2DFast256(b *testing.B) { benchmarkDCT2DFast(b, 256) }
+func BenchmarkDCT2D512(b *testing.B)     { benchmarkDCT2D(b, 512) }
+func BenchmarkDCT2DFast512(b *testing.B) { benchmarkDCT2DFast(b, 512) }
+
+func BenchmarkDCTTransform2DSerial1024(b *testing.B)   { benchmarkDCTTransform2D(b, 1, 1024, 1024) }
+func BenchmarkDCTTransform2DParallel1024(b *testing.B) { benchmarkDCTTransform2D(b, 0, 1024, 1024) }
+func BenchmarkDCTTransform2DSerial2048x512(b *testing.B) {
+	benchmarkDCTTransform2D(b, 1, 2048, 512)
+}
+func BenchmarkDCTTransform2DParallel2048x512(b *testing.B) {
+	benchmarkDCTTransform2D(b, 0, 2048, 512)
+}
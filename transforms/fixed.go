@@ -0,0 +1,238 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// The fixed-point transforms use integer arithmetic only, so that their
+// results are identical on every architecture whatever the rounding and the
+// fusion of floating-point operations.
+
+// CosBits is the number of fractional bits of the cosines of DCT2DFixed.
+const CosBits = 14
+
+// rec601Fixed converts 16 bits channels to a 16 bits Rec. 601 luma.
+// The weights sum to 1 << 16.
+func rec601Fixed(r, g, b uint32) uint32 {
+	return (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+}
+
+// fixedWeights holds the integer weights of the source pixels covered by a
+// destination pixel. The weights of a destination pixel sum to the source size.
+type fixedWeights struct {
+	start  int
+	values []uint64
+}
+
+// areaWeightsFixed returns the lengths of the overlaps between src source
+// pixels and dst destination pixels spanning the same interval, measured in
+// units of 1 / (src * dst) of the interval.
+func areaWeightsFixed(src, dst int) []fixedWeights {
+	ws := make([]fixedWeights, dst)
+	for i := range ws {
+		start, end := i*src, (i+1)*src
+		first := start / dst
+		ws[i].start = first
+		for s := first; s < src && s*dst < end; s++ {
+			lo, hi := s*dst, (s+1)*dst
+			if lo < start {
+				lo = start
+			}
+			if hi > end {
+				hi = end
+			}
+			ws[i].values = append(ws[i].values, uint64(hi-lo))
+		}
+	}
+	return ws
+}
+
+// ResampleFixed function shrinks or enlarges img to a width x height array of
+// 16 bits gray levels, stored row by row into pixels which should hold
+// width * height values. Colors are converted with the Rec. 601 luma and each
+// destination pixel is the average of the area it covers, rounded to the
+// nearest integer. Only integer arithmetic is used, so that the result is
+// identical on every architecture. It gives up with ErrCanceled once done is
+// closed. A nil done never cancels.
+func ResampleFixed(img image.Image, pixels []uint16, width, height int, done <-chan struct{}) error {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pixels = pixels[:width*height]
+	for i := range pixels {
+		pixels[i] = 0
+	}
+	if w <= 0 || h <= 0 || width <= 0 || height <= 0 {
+		return nil
+	}
+
+	xWeights := areaWeightsFixed(w, width)
+	yWeights := areaWeightsFixed(h, height)
+	// The weights sum to w horizontally and to h vertically.
+	total := uint64(w) * uint64(h)
+
+	gray := make([]uint32, w)
+	row := make([]uint64, width)
+	sums := make([]uint64, width)
+	for i, yw := range yWeights {
+		for x := range sums {
+			sums[x] = 0
+		}
+		for j, weight := range yw.values {
+			if canceled(done) {
+				return ErrCanceled
+			}
+			grayRowFixed(img, bounds.Min.Y+yw.start+j, gray)
+			for x, xw := range xWeights {
+				sum := uint64(0)
+				for k, v := range xw.values {
+					sum += uint64(gray[xw.start+k]) * v
+				}
+				row[x] = sum
+			}
+			for x, v := range row {
+				sums[x] += v * weight
+			}
+		}
+		dst := pixels[i*width : (i+1)*width]
+		for x, sum := range sums {
+			dst[x] = uint16((sum + total/2) / total)
+		}
+	}
+	return nil
+}
+
+// grayRowFixed converts the row y of img to 16 bits Rec. 601 lumas.
+func grayRowFixed(img image.Image, y int, gray []uint32) {
+	bounds := img.Bounds()
+	switch c := img.(type) {
+	case *image.YCbCr:
+		for x := range gray {
+			yi := c.YOffset(bounds.Min.X+x, y)
+			ci := c.COffset(bounds.Min.X+x, y)
+			r, g, b, _ := color.YCbCr{Y: c.Y[yi], Cb: c.Cb[ci], Cr: c.Cr[ci]}.RGBA()
+			gray[x] = rec601Fixed(r, g, b)
+		}
+	case *image.Gray:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			gray[x] = uint32(row[x]) * 0x101
+		}
+	case *image.RGBA:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			p := row[x*4 : x*4+3]
+			gray[x] = rec601Fixed(uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101)
+		}
+	default:
+		for x := range gray {
+			r, g, b, _ := img.At(bounds.Min.X+x, y).RGBA()
+			gray[x] = rec601Fixed(r, g, b)
+		}
+	}
+}
+
+// fixedCos returns cos(π m / 2n) scaled by 1 << CosBits and rounded.
+// The angle is reduced to the first quarter wave, where the cosine is
+// computed by Taylor series whose every operation is rounded by an explicit
+// conversion, which forbids fused operations. The result therefore does not
+// depend on the architecture nor on the math package.
+func fixedCos(m, n int) int64 {
+	m %= 4 * n
+	if m > 2*n {
+		m = 4*n - m
+	}
+	sign := int64(1)
+	if m > n {
+		m, sign = 2*n-m, -1
+	}
+	var v float64
+	if 2*m <= n {
+		v = cosSeries(float64(math.Pi*float64(m)) / float64(2*n))
+	} else {
+		v = sinSeries(float64(math.Pi*float64(n-m)) / float64(2*n))
+	}
+	return sign * int64(math.Floor(float64(v*(1<<CosBits))+0.5))
+}
+
+// cosSeries returns cos(x) for 0 <= x <= π/4.
+func cosSeries(x float64) float64 {
+	x2 := float64(x * x)
+	term, sum := 1.0, 1.0
+	for i := 2; i <= 20; i += 2 {
+		term = float64(-term*x2) / float64(i*(i-1))
+		sum += term
+	}
+	return sum
+}
+
+// sinSeries returns sin(x) for 0 <= x <= π/4.
+func sinSeries(x float64) float64 {
+	x2 := float64(x * x)
+	term, sum := x, x
+	for i := 3; i <= 21; i += 2 {
+		term = float64(-term*x2) / float64(i*(i-1))
+		sum += term
+	}
+	return sum
+}
+
+// DCT2DFixed function computes the top-left width x height coefficients of the
+// unscaled DCT-II of the size x size gray levels of input, stored row by row,
+// and writes them row by row into output. The cosines have CosBits fractional
+// bits and the rows are rounded back to integers before the columns are
+// transformed, so that the coefficients are scaled by 1 << CosBits. Only
+// integer arithmetic is used once the cosines are tabulated, see fixedCos.
+func DCT2DFixed(input []uint16, size, width, height int, output []int64) error {
+	if size <= 0 || size > 1<<16 {
+		return fmt.Errorf("no fixed-point DCT of size %d", size)
+	}
+	if len(input) < size*size {
+		return fmt.Errorf("input should hold %d values but holds %d", size*size, len(input))
+	}
+	if width <= 0 || height <= 0 || width > size || height > size || len(output) < width*height {
+		return fmt.Errorf("output of %dx%d coefficients does not fit a DCT of size %d", width, height, size)
+	}
+
+	n := width
+	if height > n {
+		n = height
+	}
+	// cos[k*size+i] is the weight of the value i in the coefficient k.
+	cos := make([]int64, n*size)
+	for k := 0; k < n; k++ {
+		for i := 0; i < size; i++ {
+			cos[k*size+i] = fixedCos((2*i+1)*k, size)
+		}
+	}
+
+	rows := make([]int64, size*width)
+	for y := 0; y < size; y++ {
+		in := input[y*size : (y+1)*size]
+		for k := 0; k < width; k++ {
+			c := cos[k*size : (k+1)*size]
+			sum := int64(0)
+			for i, v := range in {
+				sum += int64(v) * c[i]
+			}
+			rows[y*width+k] = (sum + 1<<(CosBits-1)) >> CosBits
+		}
+	}
+	for k := 0; k < height; k++ {
+		c := cos[k*size : (k+1)*size]
+		for x := 0; x < width; x++ {
+			sum := int64(0)
+			for y, w := range c {
+				sum += rows[y*width+x] * w
+			}
+			output[k*width+x] = sum
+		}
+	}
+	return nil
+}
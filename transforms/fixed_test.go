@@ -0,0 +1,155 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestResampleFixed(t *testing.T) {
+	// Three pixels shrunk to two: each destination pixel covers a pixel and a half.
+	gray := image.NewGray(image.Rect(0, 0, 3, 1))
+	copy(gray.Pix, []uint8{0, 30, 60})
+	pixels := make([]uint16, 2)
+	if err := ResampleFixed(gray, pixels, 2, 1, nil); err != nil {
+		t.Errorf("%s", err)
+	}
+	if expected := []uint16{10 * 0x101, 50 * 0x101}; !reflect.DeepEqual(pixels, expected) {
+		t.Errorf("ResampleFixed is expected %v but got %v", expected, pixels)
+	}
+
+	for _, img := range testImages(20, 10) {
+		for _, size := range [][2]int{{7, 3}, {20, 10}, {32, 16}} {
+			width, height := size[0], size[1]
+			fixed := make([]uint16, width*height)
+			if err := ResampleFixed(img, fixed, width, height, nil); err != nil {
+				t.Errorf("%s", err)
+			}
+			// The fast paths are identical to the generic conversion.
+			generic := make([]uint16, width*height)
+			ResampleFixed(struct{ image.Image }{img}, generic, width, height, nil)
+			if !reflect.DeepEqual(fixed, generic) {
+				t.Errorf("ResampleFixed of %T is expected %v but got %v", img, generic, fixed)
+			}
+			// The result is the rounded area average of the Rec. 601 lumas.
+			float := make([]float64, width*height)
+			Resampler{Filter: Box, Luma: Rec601}.Resample(img, float, width, height)
+			for i, v := range fixed {
+				if d := math.Abs(float64(v)/0x101 - float[i]); d > 0.02 && width <= 20 {
+					t.Errorf("ResampleFixed of %T to %dx%d is expected about %v at %d but got %v", img, width, height, float[i], i, float64(v)/0x101)
+					break
+				}
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	close(done)
+	if err := ResampleFixed(gray, pixels, 2, 1, done); err != ErrCanceled {
+		t.Errorf("ResampleFixed with a closed channel is expected %v but got %v", ErrCanceled, err)
+	}
+}
+
+func TestFixedCos(t *testing.T) {
+	for _, n := range []int{1, 2, 8, 64, 1024} {
+		for m := 0; m < 8*n; m++ {
+			v := fixedCos(m, n)
+			expected := math.Cos(math.Pi*float64(m)/float64(2*n)) * (1 << CosBits)
+			if math.Abs(float64(v)-expected) > 0.5+1e-9 {
+				t.Errorf("fixedCos(%d, %d) is expected about %v but got %d", m, n, expected, v)
+			}
+		}
+	}
+}
+
+// fixedPixels returns size x size reproducible 16 bits gray levels computed
+// with integers only.
+func fixedPixels(size int) []uint16 {
+	pixels := make([]uint16, size*size)
+	seed := uint32(size)
+	for i := range pixels {
+		seed = seed*1103515245 + 12345
+		pixels[i] = uint16(seed >> 16)
+	}
+	return pixels
+}
+
+func TestDCT2DFixed(t *testing.T) {
+	for _, size := range []int{4, 8, 64, 256} {
+		pixels := fixedPixels(size)
+		input := make([][]float64, size)
+		for i := range input {
+			input[i] = make([]float64, size)
+			for j := range input[i] {
+				input[i][j] = float64(pixels[i*size+j])
+			}
+		}
+		expected := DCT2D(input, size, size)
+
+		width, height := size/2, size/4
+		output := make([]int64, width*height)
+		if err := DCT2DFixed(pixels, size, width, height, output); err != nil {
+			t.Fatalf("%s", err)
+		}
+		for i, v := range output {
+			// Each cosine is off by at most half a unit of 1 / (1 << CosBits).
+			e := expected[i/width][i%width]
+			if d := math.Abs(float64(v)/(1<<CosBits) - e); d > float64(size)*0xffff/(1<<CosBits)*2 {
+				t.Errorf("DCT2DFixed of size %d is expected about %v at (%d, %d) but got %v", size, e, i%width, i/width, float64(v)/(1<<CosBits))
+				break
+			}
+		}
+	}
+
+	output := make([]int64, 64)
+	for _, tt := range []struct {
+		input               []uint16
+		size, width, height int
+	}{
+		{make([]uint16, 0), 0, 8, 8},
+		{make([]uint16, 63), 8, 8, 8},
+		{make([]uint16, 64*64), 64, 16, 16},
+		{make([]uint16, 4*4), 4, 8, 8},
+	} {
+		if err := DCT2DFixed(tt.input, tt.size, tt.width, tt.height, output); err == nil {
+			t.Errorf("DCT2DFixed(%d, %d, %d) is expected to fail", tt.size, tt.width, tt.height)
+		}
+	}
+}
+
+// TestDCT2DFixedGolden checks coefficients computed on amd64 so that every
+// architecture computes the same ones.
+func TestDCT2DFixedGolden(t *testing.T) {
+	for _, tt := range []struct {
+		size     int
+		expected []int64
+	}{
+		{8, []int64{29420175360, -601980928, -1005928448, -129908736, -590603373, 1442418240, 546816474, -1653867738}},
+		{32, []int64{545911242752, -5328207872, 9762701312, 917192704, 6182657048, -301750862, 3719165820, 3937261349}},
+	} {
+		output := make([]int64, 4*2)
+		if err := DCT2DFixed(fixedPixels(tt.size), tt.size, 4, 2, output); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if !reflect.DeepEqual(output, tt.expected) {
+			t.Errorf("DCT2DFixed of size %d is expected %#v but got %#v", tt.size, tt.expected, output)
+		}
+	}
+}
+
+func BenchmarkResampleFixed(b *testing.B) {
+	img := image.NewRGBA(image.Rect(0, 0, 1024, 768))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	pixels := make([]uint16, 64*64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ResampleFixed(img, pixels, 64, 64, nil)
+	}
+}
@@ -0,0 +1,411 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// The NearestNeighbor and Bilinear filters reproduce bit for bit the
+// resampling of the nfnt/resize package, which earlier versions hashed images
+// with, so that the hashes computed with the default options do not change.
+// They resample the channels of images rather than their gray scale values,
+// rows then columns, with integer weights, and round the channels to the
+// precision of the image after each pass.
+
+// legacyFormat is the color model and the precision images are resampled in.
+type legacyFormat int
+
+const (
+	// legacyRGB8 holds premultiplied 8 bits channels.
+	legacyRGB8 legacyFormat = iota
+	// legacyYCbCr8 holds 8 bits Y, Cb and Cr channels.
+	legacyYCbCr8
+	// legacyGray8 holds a 8 bits gray channel.
+	legacyGray8
+	// legacyRGB16 holds premultiplied 16 bits channels.
+	legacyRGB16
+	// legacyGray16 holds a 16 bits gray channel.
+	legacyGray16
+)
+
+// legacyFormatOf returns the format img is resampled in. Images of other
+// types than the standard ones are resampled in the format of their color
+// model, so that wrapping an image does not change its hashes.
+func legacyFormatOf(img image.Image) legacyFormat {
+	switch img.(type) {
+	case *image.RGBA, *image.NRGBA:
+		return legacyRGB8
+	case *image.YCbCr:
+		return legacyYCbCr8
+	case *image.Gray:
+		return legacyGray8
+	case *image.Gray16:
+		return legacyGray16
+	case *image.RGBA64, *image.NRGBA64:
+		return legacyRGB16
+	}
+	switch img.ColorModel() {
+	case color.RGBAModel, color.NRGBAModel:
+		return legacyRGB8
+	case color.YCbCrModel:
+		return legacyYCbCr8
+	case color.GrayModel:
+		return legacyGray8
+	case color.Gray16Model:
+		return legacyGray16
+	default:
+		return legacyRGB16
+	}
+}
+
+// channels returns the number of channels of the format.
+func (f legacyFormat) channels() int {
+	if f == legacyGray8 || f == legacyGray16 {
+		return 1
+	}
+	return 3
+}
+
+// precision returns 0 for 8 bits channels and 1 for 16 bits ones.
+func (f legacyFormat) precision() int {
+	if f == legacyRGB16 || f == legacyGray16 {
+		return 1
+	}
+	return 0
+}
+
+// gray converts the channels p of a pixel to a gray scale value with luma.
+func (f legacyFormat) gray(p []int32, luma grayFunc) float64 {
+	f.wide(p)
+	return wideGray(p, luma)
+}
+
+// wide converts in place the channels p of a pixel to 16 bits RGB or gray
+// channels.
+func (f legacyFormat) wide(p []int32) {
+	switch f {
+	case legacyRGB8:
+		p[0], p[1], p[2] = p[0]*0x101, p[1]*0x101, p[2]*0x101
+	case legacyYCbCr8:
+		r, g, b, _ := color.YCbCr{Y: uint8(p[0]), Cb: uint8(p[1]), Cr: uint8(p[2])}.RGBA()
+		p[0], p[1], p[2] = int32(r), int32(g), int32(b)
+	case legacyGray8:
+		p[0] *= 0x101
+	}
+}
+
+// wideGray converts the 16 bits RGB or gray channels p of a pixel to a gray
+// scale value with luma.
+func wideGray(p []int32, luma grayFunc) float64 {
+	if len(p) == 1 {
+		v := uint32(p[0])
+		return luma(v, v, v)
+	}
+	return luma(uint32(p[0]), uint32(p[1]), uint32(p[2]))
+}
+
+// legacyRow reads the channels of the row y of img in the format f.
+func legacyRow(img image.Image, y int, row []int32, f legacyFormat) {
+	bounds := img.Bounds()
+	w := bounds.Dx()
+	switch c := img.(type) {
+	case *image.RGBA:
+		pix := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < w; x++ {
+			row[x*3], row[x*3+1], row[x*3+2] = int32(pix[x*4]), int32(pix[x*4+1]), int32(pix[x*4+2])
+		}
+	case *image.NRGBA:
+		pix := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < w; x++ {
+			a := int32(pix[x*4+3])
+			row[x*3] = int32(pix[x*4]) * a / 0xff
+			row[x*3+1] = int32(pix[x*4+1]) * a / 0xff
+			row[x*3+2] = int32(pix[x*4+2]) * a / 0xff
+		}
+	case *image.YCbCr:
+		for x := 0; x < w; x++ {
+			ci := c.COffset(bounds.Min.X+x, y)
+			row[x*3] = int32(c.Y[c.YOffset(bounds.Min.X+x, y)])
+			row[x*3+1], row[x*3+2] = int32(c.Cb[ci]), int32(c.Cr[ci])
+		}
+	case *image.Gray:
+		pix := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < w; x++ {
+			row[x] = int32(pix[x])
+		}
+	case *image.Gray16:
+		pix := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < w; x++ {
+			row[x] = int32(pix[x*2])<<8 | int32(pix[x*2+1])
+		}
+	case *image.RGBA64:
+		pix := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < w; x++ {
+			p := pix[x*8 : x*8+6]
+			row[x*3] = int32(p[0])<<8 | int32(p[1])
+			row[x*3+1] = int32(p[2])<<8 | int32(p[3])
+			row[x*3+2] = int32(p[4])<<8 | int32(p[5])
+		}
+	case *image.NRGBA64:
+		pix := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < w; x++ {
+			p := pix[x*8 : x*8+8]
+			a := int64(p[6])<<8 | int64(p[7])
+			row[x*3] = int32((int64(p[0])<<8 | int64(p[1])) * a / 0xffff)
+			row[x*3+1] = int32((int64(p[2])<<8 | int64(p[3])) * a / 0xffff)
+			row[x*3+2] = int32((int64(p[4])<<8 | int64(p[5])) * a / 0xffff)
+		}
+	default:
+		model := img.ColorModel()
+		for x := 0; x < w; x++ {
+			legacyColor(img.At(bounds.Min.X+x, y), model, row[x*f.channels():], f)
+		}
+	}
+}
+
+// wideRow reads the channels of the row y of img in the format f, converted
+// to 16 bits RGB or gray channels. Unlike legacyRow, it premultiplies
+// non-premultiplied colors with their full 16 bits precision.
+func wideRow(img image.Image, y int, row []int32, f legacyFormat) {
+	bounds := img.Bounds()
+	n := f.channels()
+	switch c := img.(type) {
+	case *image.RGBA, *image.YCbCr, *image.Gray, *image.Gray16, *image.RGBA64, *image.NRGBA64:
+		legacyRow(img, y, row, f)
+		for x := 0; x < len(row); x += n {
+			f.wide(row[x : x+n])
+		}
+	case *image.NRGBA:
+		pix := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < len(row)/3; x++ {
+			p := pix[x*4 : x*4+4]
+			a := int32(p[3])
+			// Premultiply as color.NRGBA.RGBA does.
+			row[x*3] = int32(p[0]) * 0x101 * a / 0xff
+			row[x*3+1] = int32(p[1]) * 0x101 * a / 0xff
+			row[x*3+2] = int32(p[2]) * 0x101 * a / 0xff
+		}
+	default:
+		for x := 0; x < len(row)/n; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, y).RGBA()
+			if n == 1 {
+				row[x] = int32(r)
+			} else {
+				row[x*3], row[x*3+1], row[x*3+2] = int32(r), int32(g), int32(b)
+			}
+		}
+	}
+}
+
+// legacyColor reads the channels of the color c of an image of the color
+// model in the format f.
+func legacyColor(c color.Color, model color.Model, p []int32, f legacyFormat) {
+	switch f {
+	case legacyRGB8:
+		if model == color.NRGBAModel {
+			n := color.NRGBAModel.Convert(c).(color.NRGBA)
+			a := int32(n.A)
+			p[0], p[1], p[2] = int32(n.R)*a/0xff, int32(n.G)*a/0xff, int32(n.B)*a/0xff
+			return
+		}
+		r := color.RGBAModel.Convert(c).(color.RGBA)
+		p[0], p[1], p[2] = int32(r.R), int32(r.G), int32(r.B)
+	case legacyYCbCr8:
+		y := color.YCbCrModel.Convert(c).(color.YCbCr)
+		p[0], p[1], p[2] = int32(y.Y), int32(y.Cb), int32(y.Cr)
+	case legacyGray8:
+		p[0] = int32(color.GrayModel.Convert(c).(color.Gray).Y)
+	case legacyGray16:
+		p[0] = int32(color.Gray16Model.Convert(c).(color.Gray16).Y)
+	default:
+		r, g, b, _ := c.RGBA()
+		p[0], p[1], p[2] = int32(r), int32(g), int32(b)
+	}
+}
+
+// legacyLinear is the bilinear kernel of nfnt/resize.
+func legacyLinear(x float64) float64 {
+	x = math.Abs(x)
+	if x <= 1 {
+		return 1 - x
+	}
+	return 0
+}
+
+// legacyNearest is the nearest-neighbor kernel of nfnt/resize.
+func legacyNearest(x float64) float64 {
+	if x >= -0.5 && x < 0.5 {
+		return 1
+	}
+	return 0
+}
+
+// legacyWeights holds the integer weights of a pass of a filter, taps for
+// each destination pixel, indexed by the precision of the channels.
+type legacyWeights struct {
+	starts []int
+	taps   int
+	coeffs [2][]int32
+	// totals holds the sum of the weights of each destination pixel.
+	totals [2][]int64
+}
+
+// newLegacyWeights returns the weights resampling src pixels to dst pixels
+// with the NearestNeighbor or the Bilinear filter f. Weights are scaled by
+// 256 for 8 bits channels and by 65536 for 16 bits ones.
+func newLegacyWeights(src, dst int, f Filter) legacyWeights {
+	scale := float64(src) / float64(dst)
+	taps := 2 * int(math.Max(math.Ceil(scale), 1))
+	factor := math.Min(1/scale, 1)
+	ws := legacyWeights{starts: make([]int, dst), taps: taps}
+	for p := range ws.coeffs {
+		ws.coeffs[p] = make([]int32, dst*taps)
+		ws.totals[p] = make([]int64, dst)
+	}
+	kernel := legacyNearest
+	if f == Bilinear {
+		kernel = legacyLinear
+	}
+	for i := range ws.starts {
+		x := scale*(float64(i)+0.5) - 0.5
+		ws.starts[i] = int(x) - taps/2 + 1
+		x -= float64(ws.starts[i])
+		for j := 0; j < taps; j++ {
+			k := kernel((x - float64(j)) * factor)
+			for p, one := range []float64{256, 65536} {
+				ws.coeffs[p][i*taps+j] = int32(k * one)
+				ws.totals[p][i] += int64(ws.coeffs[p][i*taps+j])
+			}
+		}
+	}
+	return ws
+}
+
+// legacyContribution is the weight of a source row in a destination row.
+type legacyContribution struct {
+	row    int
+	weight [2]int64
+}
+
+// legacyResampling holds the weights and the sums of a NearestNeighbor or a
+// Bilinear resampling.
+type legacyResampling struct {
+	x, y legacyWeights
+	// contributions lists the destination rows each source row contributes
+	// to. Source rows past the edges are clamped to them.
+	contributions [][]legacyContribution
+	// row holds the channels of a source row resampled horizontally.
+	row []int32
+	// sums holds the weighted sums of the channels of the destination pixels.
+	sums []int64
+}
+
+// plan computes the weights resampling w x h images to width x height.
+func (l *legacyResampling) plan(w, h, width, height int, f Filter) {
+	l.x, l.y = newLegacyWeights(w, width, f), newLegacyWeights(h, height, f)
+	l.contributions = make([][]legacyContribution, h)
+	for i, start := range l.y.starts {
+	taps:
+		for j := 0; j < l.y.taps; j++ {
+			weight := [2]int64{int64(l.y.coeffs[0][i*l.y.taps+j]), int64(l.y.coeffs[1][i*l.y.taps+j])}
+			if weight == [2]int64{} {
+				continue
+			}
+			s := clampIndex(start+j, h)
+			for k, c := range l.contributions[s] {
+				if c.row == i {
+					l.contributions[s][k].weight[0] += weight[0]
+					l.contributions[s][k].weight[1] += weight[1]
+					continue taps
+				}
+			}
+			l.contributions[s] = append(l.contributions[s], legacyContribution{i, weight})
+		}
+	}
+	l.row = make([]int32, width*3)
+	l.sums = make([]int64, width*height*3)
+}
+
+// clampIndex returns i clamped to [0, n).
+func clampIndex(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+// divide returns sum / total clamped to the channels of precision p.
+func divide(sum, total int64, p int) int32 {
+	max := int64(0xff)
+	if p == 1 {
+		max = 0xffff
+	}
+	if total == 0 {
+		return 0
+	}
+	v := sum / total
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return int32(max)
+	}
+	return int32(v)
+}
+
+// add resamples horizontally the source row y, whose channels are src, and
+// adds it to the sums of the destination rows it contributes to.
+func (l *legacyResampling) add(y int, src []int32, f legacyFormat) {
+	n, p := f.channels(), f.precision()
+	last := len(src)/n - 1
+	coeffs := l.x.coeffs[p]
+	for x, start := range l.x.starts {
+		var sum [3]int64
+		for j, coeff := range coeffs[x*l.x.taps : (x+1)*l.x.taps] {
+			if coeff == 0 {
+				continue
+			}
+			s := start + j
+			if s < 0 {
+				s = 0
+			} else if s > last {
+				s = last
+			}
+			for c := 0; c < n; c++ {
+				sum[c] += int64(coeff) * int64(src[s*n+c])
+			}
+		}
+		for c := 0; c < n; c++ {
+			l.row[x*n+c] = divide(sum[c], l.x.totals[p][x], p)
+		}
+	}
+	width := len(l.x.starts)
+	for _, c := range l.contributions[y] {
+		sums := l.sums[c.row*width*n : (c.row+1)*width*n]
+		for i, v := range l.row[:width*n] {
+			sums[i] += c.weight[p] * int64(v)
+		}
+	}
+}
+
+// result stores the gray scale values of the destination pixels into pixels.
+func (l *legacyResampling) result(pixels []float64, f legacyFormat, luma grayFunc) {
+	n, p := f.channels(), f.precision()
+	width := len(l.x.starts)
+	var channels [3]int32
+	for i := range pixels {
+		total := l.y.totals[p][i/width]
+		for c := 0; c < n; c++ {
+			channels[c] = divide(l.sums[i*n+c], total, p)
+		}
+		pixels[i] = f.gray(channels[:n], luma)
+	}
+}
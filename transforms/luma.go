@@ -0,0 +1,113 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"math"
+	"sync"
+)
+
+// Luma describes the formula converting colors to gray scale values.
+// Gray scale values range from 0 to 255 whatever the formula.
+type Luma int
+
+const (
+	// Rec601Legacy is a enum value of the Rec. 601 luma computed on channels
+	// truncated to 8 bits, as Rgb2Gray does. It is the default.
+	Rec601Legacy Luma = iota
+	// Rec601 is a enum value of the Rec. 601 luma (0.299, 0.587, 0.114).
+	Rec601
+	// Rec709 is a enum value of the Rec. 709 luma (0.2126, 0.7152, 0.0722).
+	Rec709
+	// RGBAverage is a enum value of the plain average of the channels.
+	RGBAverage
+	// CIELightness is a enum value of the CIE 1976 lightness L*, scaled from [0, 100].
+	CIELightness
+	// LinearLight is a enum value of the relative luminance Y of linearized sRGB.
+	LinearLight
+)
+
+// grayFunc converts 16 bits channels, as returned by color.Color.RGBA, to a gray scale value.
+type grayFunc func(r, g, b uint32) float64
+
+// function returns the conversion of the formula.
+func (l Luma) function() grayFunc {
+	switch l {
+	case Rec601:
+		return rec601
+	case Rec709:
+		return rec709
+	case RGBAverage:
+		return rgbAverage
+	case CIELightness:
+		return cieLightness
+	case LinearLight:
+		return linearLight
+	default:
+		return rec601Legacy
+	}
+}
+
+// Gray method converts 16 bits channels, as returned by color.Color.RGBA, to a gray scale value.
+func (l Luma) Gray(r, g, b uint32) float64 {
+	return l.function()(r, g, b)
+}
+
+func rec601Legacy(r, g, b uint32) float64 {
+	return pixel2Gray(r, g, b, 0xffff)
+}
+
+func rec601(r, g, b uint32) float64 {
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+}
+
+func rec709(r, g, b uint32) float64 {
+	return (0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)) / 257
+}
+
+func rgbAverage(r, g, b uint32) float64 {
+	return float64(r+g+b) / (3 * 257)
+}
+
+var (
+	linearOnce  sync.Once
+	linearTable []float64
+)
+
+// srgbToLinear returns the linear light intensity in [0, 1] of a 16 bits sRGB channel.
+func srgbToLinear(v uint32) float64 {
+	linearOnce.Do(func() {
+		linearTable = make([]float64, 0x10000)
+		for i := range linearTable {
+			c := float64(i) / 0xffff
+			if c <= 0.04045 {
+				linearTable[i] = c / 12.92
+			} else {
+				linearTable[i] = math.Pow((c+0.055)/1.055, 2.4)
+			}
+		}
+	})
+	return linearTable[v&0xffff]
+}
+
+// relativeLuminance returns the CIE Y in [0, 1] of sRGB channels.
+func relativeLuminance(r, g, b uint32) float64 {
+	return 0.2126*srgbToLinear(r) + 0.7152*srgbToLinear(g) + 0.0722*srgbToLinear(b)
+}
+
+func linearLight(r, g, b uint32) float64 {
+	return relativeLuminance(r, g, b) * 255
+}
+
+func cieLightness(r, g, b uint32) float64 {
+	y := relativeLuminance(r, g, b)
+	var l float64
+	if y > 216.0/24389 {
+		l = 116*math.Cbrt(y) - 16
+	} else {
+		l = y * 24389 / 27
+	}
+	return l * 2.55
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestLumaGray(t *testing.T) {
+	for _, tt := range []struct {
+		luma     Luma
+		r, g, b  uint32
+		expected float64
+	}{
+		{Rec601Legacy, 0xffff, 0xffff, 0xffff, 0.299*255 + 0.587*255 + 0.114*255},
+		{Rec601, 0xffff, 0xffff, 0xffff, 255},
+		{Rec601, 0xffff, 0, 0, 0.299 * 255},
+		{Rec709, 0, 0xffff, 0, 0.7152 * 255},
+		{RGBAverage, 0xffff, 0, 0xffff, 170},
+		{CIELightness, 0, 0, 0, 0},
+		{CIELightness, 0xffff, 0xffff, 0xffff, 255},
+		{CIELightness, 0x7f7f, 0x7f7f, 0x7f7f, 53.19 * 2.55},
+		{LinearLight, 0xffff, 0xffff, 0xffff, 255},
+		{LinearLight, 0x7f7f, 0x7f7f, 0x7f7f, 0.2122 * 255},
+	} {
+		if v := tt.luma.Gray(tt.r, tt.g, tt.b); math.Abs(v-tt.expected) > 0.1 {
+			t.Errorf("Luma %d of (%#x, %#x, %#x) is expected %v but got %v", tt.luma, tt.r, tt.g, tt.b, tt.expected, v)
+		}
+	}
+}
+
+func TestResampleGray16(t *testing.T) {
+	// Shades differing only in their low 8 bits should stay distinct.
+	img := image.NewGray16(image.Rect(0, 0, 2, 1))
+	img.SetGray16(0, 0, color.Gray16{Y: 0x8000})
+	img.SetGray16(1, 0, color.Gray16{Y: 0x80ff})
+
+	for _, luma := range []Luma{Rec601, Rec709, RGBAverage, CIELightness, LinearLight} {
+		pixels := make([]float64, 2)
+		Resampler{Filter: NearestNeighbor, Luma: luma}.Resample(img, pixels, 2, 1)
+		if pixels[0] >= pixels[1] {
+			t.Errorf("Luma %d of Gray16 is expected to keep 16 bits precision but got %v", luma, pixels)
+		}
+	}
+}
+
+func TestResampleLumaImageTypes(t *testing.T) {
+	// The fast paths should give the same result as the image.Image interface.
+	for _, luma := range []Luma{Rec601Legacy, Rec601, Rec709, RGBAverage, CIELightness, LinearLight} {
+		r := Resampler{Filter: Bicubic, Luma: luma}
+		for _, img := range testImages(20, 10) {
+			fast := make([]float64, 4*4)
+			slow := make([]float64, 4*4)
+			r.Resample(img, fast, 4, 4)
+			r.Resample(struct{ image.Image }{img}, slow, 4, 4)
+			for i := range fast {
+				if math.Abs(fast[i]-slow[i]) > 1e-9 {
+					t.Errorf("Luma %d of %T is expected %v but got %v", luma, img, slow, fast)
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+)
+
+// Orient function returns img as it should be displayed according to an EXIF
+// orientation from 1 to 8. Unknown orientations and 1 return img itself.
+// The standard image types are copied into an image of the same type, an
+// *image.YCbCr with 4:4:4 sampling for *image.YCbCr, so that the fast paths
+// of the resamplers read them and their colors are unchanged. Other images
+// are not copied, the result reads their pixels from img.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	o := &oriented{img, orientation}
+	b := img.Bounds()
+	r := o.Bounds()
+	switch src := img.(type) {
+	case *image.Gray:
+		dst := image.NewGray(r)
+		o.copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, 1)
+		return dst
+	case *image.Gray16:
+		dst := image.NewGray16(r)
+		o.copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, 2)
+		return dst
+	case *image.RGBA:
+		dst := image.NewRGBA(r)
+		o.copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, 4)
+		return dst
+	case *image.RGBA64:
+		dst := image.NewRGBA64(r)
+		o.copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, 8)
+		return dst
+	case *image.NRGBA:
+		dst := image.NewNRGBA(r)
+		o.copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, 4)
+		return dst
+	case *image.NRGBA64:
+		dst := image.NewNRGBA64(r)
+		o.copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, 8)
+		return dst
+	case *image.CMYK:
+		dst := image.NewCMYK(r)
+		o.copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, 4)
+		return dst
+	case *image.Paletted:
+		dst := image.NewPaletted(r, src.Palette)
+		o.copyPix(dst.Pix, dst.Stride, src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], src.Stride, 1)
+		return dst
+	case *image.YCbCr:
+		// Every pixel keeps its own chroma, whatever the subsampling of src.
+		dst := image.NewYCbCr(r, image.YCbCrSubsampleRatio444)
+		for y := 0; y < r.Dy(); y++ {
+			for x := 0; x < r.Dx(); x++ {
+				sx, sy := o.source(x, y)
+				yi := src.YOffset(b.Min.X+sx, b.Min.Y+sy)
+				ci := src.COffset(b.Min.X+sx, b.Min.Y+sy)
+				i := y*dst.YStride + x
+				dst.Y[i], dst.Cb[i], dst.Cr[i] = src.Y[yi], src.Cb[ci], src.Cr[ci]
+			}
+		}
+		return dst
+	}
+	return o
+}
+
+// oriented maps the pixels of an image flipped and rotated as an EXIF orientation.
+type oriented struct {
+	img         image.Image
+	orientation int
+}
+
+func (o *oriented) ColorModel() color.Model {
+	return o.img.ColorModel()
+}
+
+func (o *oriented) Bounds() image.Rectangle {
+	b := o.img.Bounds()
+	if o.orientation >= 5 {
+		return image.Rect(0, 0, b.Dy(), b.Dx())
+	}
+	return image.Rect(0, 0, b.Dx(), b.Dy())
+}
+
+func (o *oriented) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(o.Bounds())) {
+		return o.img.ColorModel().Convert(color.Transparent)
+	}
+	b := o.img.Bounds()
+	sx, sy := o.source(x, y)
+	return o.img.At(b.Min.X+sx, b.Min.Y+sy)
+}
+
+// source returns the coordinates, relative to the bounds of img, of the
+// pixel displayed at (x, y).
+func (o *oriented) source(x, y int) (sx, sy int) {
+	b := o.img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	switch o.orientation {
+	case 2: // mirrored horizontally
+		return w - 1 - x, y
+	case 3: // rotated 180°
+		return w - 1 - x, h - 1 - y
+	case 4: // mirrored vertically
+		return x, h - 1 - y
+	case 5: // transposed
+		return y, x
+	case 6: // rotated 90° clockwise to display
+		return y, h - 1 - x
+	case 7: // transversed
+		return w - 1 - y, h - 1 - x
+	case 8: // rotated 90° counterclockwise to display
+		return w - 1 - y, x
+	}
+	return x, y
+}
+
+// copyPix copies the pixels of n bytes of img, stored in src from its top-left
+// pixel, into dst oriented.
+func (o *oriented) copyPix(dst []uint8, dstStride int, src []uint8, srcStride, n int) {
+	r := o.Bounds()
+	for y := 0; y < r.Dy(); y++ {
+		row := dst[y*dstStride : y*dstStride+r.Dx()*n]
+		for x := 0; x < r.Dx(); x++ {
+			sx, sy := o.source(x, y)
+			copy(row[x*n:x*n+n], src[sy*srcStride+sx*n:])
+		}
+	}
+}
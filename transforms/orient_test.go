@@ -0,0 +1,87 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestOrient(t *testing.T) {
+	// 0 1 2
+	// 3 4 5
+	img := image.NewGray(image.Rect(10, 20, 13, 22))
+	for i := range img.Pix[:6] {
+		img.Pix[i/3*img.Stride+i%3] = uint8(i)
+	}
+
+	for _, tt := range []struct {
+		orientation int
+		expected    [][]uint8
+	}{
+		{1, [][]uint8{{0, 1, 2}, {3, 4, 5}}},
+		{2, [][]uint8{{2, 1, 0}, {5, 4, 3}}},
+		{3, [][]uint8{{5, 4, 3}, {2, 1, 0}}},
+		{4, [][]uint8{{3, 4, 5}, {0, 1, 2}}},
+		{5, [][]uint8{{0, 3}, {1, 4}, {2, 5}}},
+		{6, [][]uint8{{3, 0}, {4, 1}, {5, 2}}},
+		{7, [][]uint8{{5, 2}, {4, 1}, {3, 0}}},
+		{8, [][]uint8{{2, 5}, {1, 4}, {0, 3}}},
+	} {
+		out := Orient(img, tt.orientation)
+		b := out.Bounds()
+		if b.Dx() != len(tt.expected[0]) || b.Dy() != len(tt.expected) {
+			t.Errorf("Orient(%d) is expected %dx%d but got %v", tt.orientation, len(tt.expected[0]), len(tt.expected), b)
+			continue
+		}
+		for y, row := range tt.expected {
+			for x, v := range row {
+				if g := color.GrayModel.Convert(out.At(b.Min.X+x, b.Min.Y+y)).(color.Gray); g.Y != v {
+					t.Errorf("Orient(%d) at (%d, %d) is expected %d but got %d", tt.orientation, x, y, v, g.Y)
+				}
+			}
+		}
+	}
+
+	if out := Orient(img, 0); out != image.Image(img) {
+		t.Errorf("Orient with an unknown orientation is expected to return the image")
+	}
+}
+
+func TestOrientImageTypes(t *testing.T) {
+	for _, img := range testImages(7, 5) {
+		// Non-zero bounds check that the copies start at the top-left pixel.
+		if s, ok := img.(interface {
+			SubImage(image.Rectangle) image.Image
+		}); ok {
+			img = s.SubImage(image.Rect(1, 1, 7, 5))
+		}
+		for orientation := 2; orientation <= 8; orientation++ {
+			out := Orient(img, orientation)
+			if _, ok := out.(*oriented); ok {
+				t.Errorf("Orient of %T is expected to copy the pixels", img)
+				continue
+			}
+			expected := &oriented{img, orientation}
+			if out.Bounds() != expected.Bounds() {
+				t.Errorf("Orient(%d) of %T is expected bounds %v but got %v", orientation, img, expected.Bounds(), out.Bounds())
+				continue
+			}
+			b := out.Bounds()
+		loop:
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					r1, g1, b1, a1 := out.At(x, y).RGBA()
+					r2, g2, b2, a2 := expected.At(x, y).RGBA()
+					if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+						t.Errorf("Orient(%d) of %T at (%d, %d) is expected %v but got %v", orientation, img, x, y, expected.At(x, y), out.At(x, y))
+						break loop
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// PILGray function converts img to an 8 bits gray scale image the same way
+// as Pillow's Image.convert("L"), using ITU-R 601-2 luma in 16 bits fixed point.
+func PILGray(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	if src, ok := img.(*image.Gray); ok {
+		for y := 0; y < bounds.Dy(); y++ {
+			copy(gray.Pix[y*gray.Stride:y*gray.Stride+bounds.Dx()], src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y+y):])
+		}
+		return gray
+	}
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			l := uint32(c.R)*19595 + uint32(c.G)*38470 + uint32(c.B)*7471 + 0x8000
+			gray.Pix[y*gray.Stride+x] = uint8(l >> 16)
+		}
+	}
+	return gray
+}
+
+// pilPrecisionBits is the fixed point precision of Pillow's 8 bits resampling.
+const pilPrecisionBits = 32 - 8 - 2
+
+// pilLanczos is Pillow's LANCZOS (formerly ANTIALIAS) filter.
+func pilLanczos(x float64) float64 {
+	if -3.0 <= x && x < 3.0 {
+		return pilSinc(x) * pilSinc(x/3)
+	}
+	return 0
+}
+
+func pilSinc(x float64) float64 {
+	if x == 0.0 {
+		return 1.0
+	}
+	x = x * math.Pi
+	return math.Sin(x) / x
+}
+
+// pilCoeffs returns the fixed point filter coefficients of each output pixel,
+// the first input pixel they apply to and the kernel size, following
+// precompute_coeffs and normalize_coeffs_8bpc of Pillow's Resample.c.
+func pilCoeffs(inSize, outSize int) (kk []int32, bounds []int, ksize int) {
+	const support = 3.0
+	scale := float64(inSize) / float64(outSize)
+	filterScale := scale
+	if filterScale < 1.0 {
+		filterScale = 1.0
+	}
+	sup := support * filterScale
+	ksize = int(math.Ceil(sup))*2 + 1
+
+	kk = make([]int32, outSize*ksize)
+	bounds = make([]int, outSize*2)
+	k := make([]float64, ksize)
+	for xx := 0; xx < outSize; xx++ {
+		center := (float64(xx) + 0.5) * scale
+		ww := 0.0
+		ss := 1.0 / filterScale
+		xmin := int(center - sup + 0.5)
+		if xmin < 0 {
+			xmin = 0
+		}
+		xmax := int(center + sup + 0.5)
+		if xmax > inSize {
+			xmax = inSize
+		}
+		xmax -= xmin
+		for x := 0; x < xmax; x++ {
+			w := pilLanczos((float64(x+xmin) - center + 0.5) * ss)
+			k[x] = w
+			ww += w
+		}
+		for x := 0; x < xmax; x++ {
+			if ww != 0.0 {
+				k[x] /= ww
+			}
+		}
+		for x := 0; x < xmax; x++ {
+			if k[x] < 0 {
+				kk[xx*ksize+x] = int32(-0.5 + float64(k[x]*(1<<pilPrecisionBits)))
+			} else {
+				kk[xx*ksize+x] = int32(0.5 + float64(k[x]*(1<<pilPrecisionBits)))
+			}
+		}
+		bounds[xx*2], bounds[xx*2+1] = xmin, xmax
+	}
+	return kk, bounds, ksize
+}
+
+func pilClip8(v int32) uint8 {
+	v >>= pilPrecisionBits
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// PILResize function resizes an 8 bits gray scale image to width x height
+// with a port of the LANCZOS (ANTIALIAS) filter of Pillow's Image.resize.
+// It is not checked against Pillow, so that its pixels may be off by one.
+func PILResize(src *image.Gray, width, height int) *image.Gray {
+	bounds := src.Bounds()
+	inW, inH := bounds.Dx(), bounds.Dy()
+	if inW == width && inH == height {
+		dst := image.NewGray(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			copy(dst.Pix[y*dst.Stride:y*dst.Stride+width], src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y+y):])
+		}
+		return dst
+	}
+
+	// Horizontal pass.
+	tmp := image.NewGray(image.Rect(0, 0, width, inH))
+	if width != inW {
+		kk, xb, ksize := pilCoeffs(inW, width)
+		for y := 0; y < inH; y++ {
+			row := src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+			for xx := 0; xx < width; xx++ {
+				xmin, xmax := xb[xx*2], xb[xx*2+1]
+				k := kk[xx*ksize:]
+				ss := int32(1) << (pilPrecisionBits - 1)
+				for x := 0; x < xmax; x++ {
+					ss += int32(row[x+xmin]) * k[x]
+				}
+				tmp.Pix[y*tmp.Stride+xx] = pilClip8(ss)
+			}
+		}
+	} else {
+		for y := 0; y < inH; y++ {
+			copy(tmp.Pix[y*tmp.Stride:y*tmp.Stride+width], src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y+y):])
+		}
+	}
+	if height == inH {
+		return tmp
+	}
+
+	// Vertical pass.
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	kk, yb, ksize := pilCoeffs(inH, height)
+	for yy := 0; yy < height; yy++ {
+		ymin, ymax := yb[yy*2], yb[yy*2+1]
+		k := kk[yy*ksize:]
+		for xx := 0; xx < width; xx++ {
+			ss := int32(1) << (pilPrecisionBits - 1)
+			for y := 0; y < ymax; y++ {
+				ss += int32(tmp.Pix[(y+ymin)*tmp.Stride+xx]) * k[y]
+			}
+			dst.Pix[yy*dst.Stride+xx] = pilClip8(ss)
+		}
+	}
+	return dst
+}
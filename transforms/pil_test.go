@@ -0,0 +1,57 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPILGray(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 5, 1))
+	for x, c := range []color.NRGBA{
+		{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 255, 255}, {12, 34, 56, 255},
+	} {
+		img.SetNRGBA(x, 0, c)
+	}
+	// (r * 19595 + g * 38470 + b * 7471 + 0x8000) >> 16
+	expected := []uint8{76, 150, 29, 255, 30}
+	gray := PILGray(img)
+	for x, v := range expected {
+		if gray.Pix[x] != v {
+			t.Errorf("PILGray at %d is expected %v but got %v", x, v, gray.Pix[x])
+		}
+	}
+}
+
+func TestPILResize(t *testing.T) {
+	uniform := image.NewGray(image.Rect(0, 0, 37, 23))
+	for i := range uniform.Pix {
+		uniform.Pix[i] = 200
+	}
+	for _, size := range [][2]int{{8, 8}, {9, 8}, {37, 8}, {8, 23}, {64, 64}} {
+		out := PILResize(uniform, size[0], size[1])
+		if out.Bounds().Dx() != size[0] || out.Bounds().Dy() != size[1] {
+			t.Errorf("PILResize to %v returns bounds %v", size, out.Bounds())
+		}
+		for i, v := range out.Pix {
+			if v != 200 {
+				t.Errorf("PILResize of a uniform image to %v is expected 200 but got %v at %d", size, v, i)
+				break
+			}
+		}
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 4, 2))
+	copy(img.Pix, []uint8{1, 2, 3, 4, 5, 6, 7, 8})
+	out := PILResize(img.SubImage(image.Rect(1, 0, 3, 2)).(*image.Gray), 2, 2)
+	for i, v := range []uint8{2, 3, 6, 7} {
+		if out.Pix[i] != v {
+			t.Errorf("PILResize to the same size is expected a copy but got %v", out.Pix)
+			break
+		}
+	}
+}
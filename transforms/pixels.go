@@ -5,6 +5,7 @@
 package transforms
 
 import (
+	"fmt"
 	"image"
 )
 
@@ -17,7 +18,7 @@ func Rgb2Gray(colorImg image.Image) [][]float64 {
 	for i := range pixels {
 		pixels[i] = make([]float64, w)
 		for j := range pixels[i] {
-			color := colorImg.At(j, i)
+			color := colorImg.At(bounds.Min.X+j, bounds.Min.Y+i)
 			r, g, b, _ := color.RGBA()
 			lum := 0.299*float64(r/257) + 0.587*float64(g/257) + 0.114*float64(b/256)
 			pixels[i][j] = lum
@@ -27,21 +28,22 @@ func Rgb2Gray(colorImg image.Image) [][]float64 {
 	return pixels
 }
 
-// Rgb2GrayFast function converts RGB to a gray scale array.
-func Rgb2GrayFast(colorImg image.Image, pixels *[]float64) {
+// Rgb2GrayFast function converts RGB to a gray scale array stored row by row
+// into pixels, which should hold at least width * height values. It gives the
+// same values as Rgb2Gray but reads the pixel buffers of the standard image
+// types directly.
+func Rgb2GrayFast(colorImg image.Image, pixels *[]float64) error {
 	bounds := colorImg.Bounds()
 	w, h := bounds.Max.X-bounds.Min.X, bounds.Max.Y-bounds.Min.Y
-	if w != h {
-		return
+	if len(*pixels) < w*h {
+		return fmt.Errorf("pixels should hold %d values for a %dx%d image but holds %d", w*h, w, h, len(*pixels))
 	}
-	switch c := colorImg.(type) {
-	case *image.YCbCr:
-		rgb2GrayYCbCR(c, *pixels, w)
-	case *image.RGBA:
-		rgb2GrayRGBA(c, *pixels, w)
-	default:
-		rgb2GrayDefault(c, *pixels, w)
+	var palette [256]float64
+	grayPalette(colorImg, rec601Legacy, &palette)
+	for i := 0; i < h; i++ {
+		grayRow(colorImg, bounds.Min.Y+i, (*pixels)[i*w:(i+1)*w], rec601Legacy, &palette)
 	}
+	return nil
 }
 
 // pixel2Gray converts a pixel to grayscale value base on luminosity
@@ -49,33 +51,6 @@ func pixel2Gray(r, g, b, a uint32) float64 {
 	return 0.299*float64(r/257) + 0.587*float64(g/257) + 0.114*float64(b/256)
 }
 
-// rgb2GrayDefault uses the image.Image interface
-func rgb2GrayDefault(colorImg image.Image, pixels []float64, s int) {
-	for i := 0; i < s; i++ {
-		for j := 0; j < s; j++ {
-			pixels[j+(i*s)] = pixel2Gray(colorImg.At(j, i).RGBA())
-		}
-	}
-}
-
-// rgb2GrayYCbCR uses *image.YCbCr which is significantly faster than the image.Image interface.
-func rgb2GrayYCbCR(colorImg *image.YCbCr, pixels []float64, s int) {
-	for i := 0; i < s; i++ {
-		for j := 0; j < s; j++ {
-			pixels[j+(i*s)] = pixel2Gray(colorImg.YCbCrAt(j, i).RGBA())
-		}
-	}
-}
-
-// rgb2GrayRGBA uses *image.RGBA which is significantly faster than the image.Image interface.
-func rgb2GrayRGBA(colorImg *image.RGBA, pixels []float64, s int) {
-	for i := 0; i < s; i++ {
-		for j := 0; j < s; j++ {
-			pixels[(i*s)+j] = pixel2Gray(colorImg.At(j, i).RGBA())
-		}
-	}
-}
-
 // FlattenPixels function flattens 2d array into 1d array.
 func FlattenPixels(pixels [][]float64, x int, y int) []float64 {
 	flattens := make([]float64, x*y)
@@ -0,0 +1,126 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"testing"
+)
+
+func TestRgb2GraySubImage(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 12, 12))
+	ycbcr := image.NewYCbCr(rgba.Bounds(), image.YCbCrSubsampleRatio444)
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 12; x++ {
+			c := color.RGBA{uint8(x * 20), uint8(y * 20), uint8(x * y), 255}
+			rgba.SetRGBA(x, y, c)
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			ycbcr.Y[ycbcr.YOffset(x, y)] = yy
+			ycbcr.Cb[ycbcr.COffset(x, y)] = cb
+			ycbcr.Cr[ycbcr.COffset(x, y)] = cr
+		}
+	}
+	rect := image.Rect(3, 5, 9, 11)
+
+	for _, img := range []image.Image{
+		rgba.SubImage(rect),
+		ycbcr.SubImage(rect),
+		struct{ image.Image }{rgba.SubImage(rect)},
+	} {
+		expected := make([]float64, rect.Dx()*rect.Dy())
+		for y := 0; y < rect.Dy(); y++ {
+			for x := 0; x < rect.Dx(); x++ {
+				expected[y*rect.Dx()+x] = pixel2Gray(img.At(rect.Min.X+x, rect.Min.Y+y).RGBA())
+			}
+		}
+
+		pixels := Rgb2Gray(img)
+		fast := make([]float64, len(expected))
+		Rgb2GrayFast(img, &fast)
+		for i, v := range expected {
+			if pixels[i/rect.Dx()][i%rect.Dx()] != v {
+				t.Errorf("Rgb2Gray of a sub-image of %T is expected %v at %d but got %v", img, v, i, pixels[i/rect.Dx()][i%rect.Dx()])
+				break
+			}
+			if fast[i] != v {
+				t.Errorf("Rgb2GrayFast of a sub-image of %T is expected %v at %d but got %v", img, v, i, fast[i])
+				break
+			}
+		}
+	}
+}
+
+// testImages returns a w x h picture in every standard image type.
+func testImages(w, h int) []image.Image {
+	rect := image.Rect(0, 0, w, h)
+	nrgba := image.NewNRGBA(rect)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			nrgba.SetNRGBA(x, y, color.NRGBA{uint8(x * 23), uint8(y * 37), uint8(x*y + 11), uint8(255 - x*3)})
+		}
+	}
+	images := []image.Image{nrgba}
+	for _, m := range []draw.Image{
+		image.NewRGBA(rect),
+		image.NewRGBA64(rect),
+		image.NewNRGBA64(rect),
+		image.NewGray(rect),
+		image.NewGray16(rect),
+		image.NewCMYK(rect),
+		image.NewPaletted(rect, palette.WebSafe),
+	} {
+		draw.Draw(m, rect, nrgba, image.ZP, draw.Src)
+		images = append(images, m)
+	}
+	for _, ratio := range []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio422,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440,
+		image.YCbCrSubsampleRatio411,
+		image.YCbCrSubsampleRatio410,
+	} {
+		m := image.NewYCbCr(rect, ratio)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				r, g, b, _ := nrgba.At(x, y).RGBA()
+				yy, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+				m.Y[m.YOffset(x, y)] = yy
+				m.Cb[m.COffset(x, y)] = cb
+				m.Cr[m.COffset(x, y)] = cr
+			}
+		}
+		images = append(images, m)
+	}
+	return images
+}
+
+func TestRgb2GrayFast(t *testing.T) {
+	for _, size := range [][2]int{{8, 8}, {13, 7}, {5, 17}} {
+		w, h := size[0], size[1]
+		for _, img := range testImages(w, h) {
+			expected := Rgb2Gray(img)
+			pixels := make([]float64, w*h)
+			if err := Rgb2GrayFast(img, &pixels); err != nil {
+				t.Errorf("%s", err)
+				continue
+			}
+			for i, v := range pixels {
+				if v != expected[i/w][i%w] {
+					t.Errorf("Rgb2GrayFast of a %dx%d %T is expected %v at %d but got %v", w, h, img, expected[i/w][i%w], i, v)
+					break
+				}
+			}
+		}
+	}
+
+	pixels := make([]float64, 8*8-1)
+	if err := Rgb2GrayFast(image.NewGray(image.Rect(0, 0, 8, 8)), &pixels); err == nil {
+		t.Errorf("Rgb2GrayFast into a too small buffer is expected to fail")
+	}
+}
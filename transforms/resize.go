@@ -0,0 +1,619 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ErrCanceled is returned when a transform is aborted by closing its done channel.
+var ErrCanceled = errors.New("transforms: canceled")
+
+// canceled reports whether done is closed. A nil done is never closed.
+func canceled(done <-chan struct{}) bool {
+	if done == nil {
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Filter describes the interpolation kernel used by Resample.
+type Filter int
+
+const (
+	// NearestNeighbor is a enum value of the nearest-neighbor interpolation.
+	// When shrinking, it averages the source pixels nearest to each
+	// destination pixel, as nfnt/resize does.
+	NearestNeighbor Filter = iota
+	// Bilinear is a enum value of the bilinear interpolation. It gives the
+	// same results as nfnt/resize, which earlier versions resized with.
+	Bilinear
+	// Bicubic is a enum value of the bicubic interpolation (Catmull-Rom).
+	Bicubic
+	// Lanczos2 is a enum value of the Lanczos interpolation (a=2).
+	Lanczos2
+	// Lanczos3 is a enum value of the Lanczos interpolation (a=3).
+	Lanczos3
+	// Box is a enum value of the area-average interpolation. It averages
+	// the channels of the pixels, rounded to 16 bits, before converting them
+	// to gray scale.
+	Box
+)
+
+// reduceRatio is the downscale ratio above which images are first shrunk by
+// area averaging to twice the target size before the filter is applied.
+const reduceRatio = 4
+
+// kernel returns the support and the function of the filter.
+func (f Filter) kernel() (float64, func(float64) float64) {
+	switch f {
+	case Bicubic:
+		return 2, cubic
+	case Lanczos2:
+		return 2, lanczos2
+	case Lanczos3:
+		return 3, lanczos3
+	default:
+		return 1, linear
+	}
+}
+
+func linear(x float64) float64 {
+	x = math.Abs(x)
+	if x < 1 {
+		return 1 - x
+	}
+	return 0
+}
+
+func cubic(x float64) float64 {
+	x = math.Abs(x)
+	if x < 1 {
+		return x*x*(1.5*x-2.5) + 1.0
+	}
+	if x < 2 {
+		return x*(x*(2.5-0.5*x)-4.0) + 2.0
+	}
+	return 0
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+func lanczos2(x float64) float64 {
+	if x > -2 && x < 2 {
+		return sinc(x) * sinc(x/2)
+	}
+	return 0
+}
+
+func lanczos3(x float64) float64 {
+	if x > -3 && x < 3 {
+		return sinc(x) * sinc(x/3)
+	}
+	return 0
+}
+
+// weights holds the contiguous source pixels contributing to a destination pixel.
+type weights struct {
+	start  int
+	values []float64
+}
+
+// nearestWeights picks the source pixel at the center of each destination pixel.
+func nearestWeights(src, dst int) []weights {
+	ws := make([]weights, dst)
+	scale := float64(src) / float64(dst)
+	for i := range ws {
+		s := int((float64(i) + 0.5) * scale)
+		if s >= src {
+			s = src - 1
+		}
+		ws[i] = weights{start: s, values: []float64{1}}
+	}
+	return ws
+}
+
+// areaWeights averages the source pixels covered by each destination pixel.
+func areaWeights(src, dst int) []weights {
+	ws := make([]weights, dst)
+	scale := float64(src) / float64(dst)
+	for i := range ws {
+		start := float64(i) * scale
+		end := start + scale
+		first := int(start)
+		for s := first; s < src && float64(s) < end; s++ {
+			w := 1.0
+			if float64(s) < start {
+				w -= start - float64(s)
+			}
+			if float64(s+1) > end {
+				w -= float64(s+1) - end
+			}
+			ws[i].values = append(ws[i].values, w/scale)
+		}
+		ws[i].start = first
+	}
+	return ws
+}
+
+// filterWeights computes normalized weights of the filter kernel, widened by
+// the downscale ratio so that downscaling does not alias.
+func filterWeights(src, dst int, f Filter) []weights {
+	support, kernel := f.kernel()
+	scale := float64(src) / float64(dst)
+	filterScale := math.Max(scale, 1)
+	support *= filterScale
+
+	ws := make([]weights, dst)
+	for i := range ws {
+		center := (float64(i) + 0.5) * scale
+		first := int(math.Max(math.Floor(center-support+0.5), 0))
+		last := int(math.Min(math.Floor(center+support+0.5), float64(src)))
+		values := make([]float64, 0, last-first)
+		sum := 0.0
+		for s := first; s < last; s++ {
+			w := kernel((float64(s) - center + 0.5) / filterScale)
+			values = append(values, w)
+			sum += w
+		}
+		if sum != 0 {
+			for j := range values {
+				values[j] /= sum
+			}
+		}
+		ws[i] = weights{start: first, values: values}
+	}
+	return ws
+}
+
+// compose returns the weights of applying inner and then outer.
+func compose(inner, outer []weights) []weights {
+	ws := make([]weights, len(outer))
+	for i, o := range outer {
+		first, last := -1, 0
+		for j := range o.values {
+			in := inner[o.start+j]
+			if first < 0 || in.start < first {
+				first = in.start
+			}
+			if end := in.start + len(in.values); end > last {
+				last = end
+			}
+		}
+		values := make([]float64, last-first)
+		for j, ow := range o.values {
+			in := inner[o.start+j]
+			for k, iw := range in.values {
+				values[in.start-first+k] += ow * iw
+			}
+		}
+		ws[i] = weights{start: first, values: values}
+	}
+	return ws
+}
+
+// resampleWeights returns the weights shrinking src pixels to dst pixels.
+func resampleWeights(src, dst int, f Filter) []weights {
+	switch {
+	case f == NearestNeighbor:
+		return nearestWeights(src, dst)
+	case f == Box:
+		if src <= dst {
+			return nearestWeights(src, dst)
+		}
+		return areaWeights(src, dst)
+	case src > reduceRatio*dst:
+		return compose(areaWeights(src, 2*dst), filterWeights(2*dst, dst, f))
+	default:
+		return filterWeights(src, dst, f)
+	}
+}
+
+// Resampler shrinks or enlarges images to gray scale arrays.
+// The zero value resamples with NearestNeighbor and Rec601Legacy.
+type Resampler struct {
+	// Filter is the interpolation kernel.
+	Filter Filter
+	// Luma is the gray scale conversion of the source pixels.
+	Luma Luma
+	// Done, if not nil, aborts the resampling with ErrCanceled once closed.
+	Done <-chan struct{}
+}
+
+// Resample function shrinks or enlarges img to a width x height gray scale
+// array, stored row by row into pixels which should hold width * height values.
+// It converts colors with Rec601Legacy and returns the error of
+// Resampler.Resample.
+func Resample(img image.Image, pixels []float64, width, height int, f Filter) error {
+	return Resampler{Filter: f}.Resample(img, pixels, width, height)
+}
+
+// Resample method shrinks or enlarges img to a width x height gray scale
+// array, stored row by row into pixels which should hold width * height values.
+// The gray scale conversion is fused with the resampling so that every source
+// pixel is read only once and no intermediate image is allocated.
+func (r Resampler) Resample(img image.Image, pixels []float64, width, height int) error {
+	return r.ResampleAll(img, []Target{{Pixels: pixels, Width: width, Height: height}})
+}
+
+// Target is a gray scale array filled by Resampler.ResampleAll.
+type Target struct {
+	// Pixels stores the Width x Height values row by row.
+	Pixels        []float64
+	Width, Height int
+}
+
+// check returns an error if t has no pixel or Pixels is too short.
+func (t Target) check() error {
+	if t.Width <= 0 || t.Height <= 0 {
+		return fmt.Errorf("Resample to %dx%d values: sizes should be positive", t.Width, t.Height)
+	}
+	if len(t.Pixels) < t.Width*t.Height {
+		return fmt.Errorf("Resample to %dx%d values: pixels holds %d values", t.Width, t.Height, len(t.Pixels))
+	}
+	return nil
+}
+
+// ResampleAll method fills every target as Resample would, but converts each
+// source row to gray scale only once for all of them.
+func (r Resampler) ResampleAll(img image.Image, targets []Target) error {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	resamplings := make([]resampling, len(targets))
+	for i, t := range targets {
+		if err := t.check(); err != nil {
+			return err
+		}
+		resamplings[i].plan(t, w, h, r.Filter)
+	}
+	return r.resample(img, resamplings, make([]float64, w), make([]int32, 6*w))
+}
+
+// contribution is the weight of a source row in a destination row.
+type contribution struct {
+	row    int
+	weight float64
+}
+
+// resampling holds the weights resampling images of a size to a target.
+type resampling struct {
+	Target
+	srcWidth, srcHeight int
+	filter              Filter
+	xWeights            []weights
+	// contributions lists the destination rows each source row contributes to.
+	contributions [][]contribution
+	row           []float64
+	// legacy holds the weights of the NearestNeighbor and Bilinear filters
+	// when they change the size of images.
+	legacy *legacyResampling
+	// channels holds the sums of the 16 bits channels of the destination
+	// pixels when Box changes the size of images. Averaging the channels
+	// rather than the gray scale values, and rounding them to 16 bits, keeps
+	// equal the pixels of flat areas, as the images earlier versions hashed.
+	channels []float64
+}
+
+// plan computes the weights resampling w x h images to t with f.
+func (rs *resampling) plan(t Target, w, h int, f Filter) {
+	*rs = resampling{Target: t, srcWidth: w, srcHeight: h, filter: f}
+	if w <= 0 || h <= 0 || t.Width <= 0 || t.Height <= 0 {
+		return
+	}
+	if (f == NearestNeighbor || f == Bilinear) && (w != t.Width || h != t.Height) {
+		rs.legacy = &legacyResampling{}
+		rs.legacy.plan(w, h, t.Width, t.Height, f)
+		return
+	}
+	rs.contributions = make([][]contribution, h)
+	for i, ws := range resampleWeights(h, t.Height, f) {
+		for j, weight := range ws.values {
+			if weight != 0 {
+				rs.contributions[ws.start+j] = append(rs.contributions[ws.start+j], contribution{i, weight})
+			}
+		}
+	}
+	rs.xWeights = resampleWeights(w, t.Width, f)
+	rs.row = make([]float64, t.Width)
+	if f == Box && (w != t.Width || h != t.Height) {
+		rs.row = make([]float64, 3*t.Width)
+		rs.channels = make([]float64, 3*t.Width*t.Height)
+	}
+}
+
+// active reports whether the target holds pixels.
+func (rs *resampling) active() bool {
+	return rs.contributions != nil || rs.legacy != nil
+}
+
+// uses reports whether the source row y contributes to the target.
+func (rs *resampling) uses(y int) bool {
+	if rs.legacy != nil {
+		return len(rs.legacy.contributions[y]) != 0
+	}
+	return rs.contributions != nil && len(rs.contributions[y]) != 0
+}
+
+// addChannels resamples horizontally the source row y, whose n channels per
+// pixel are src, and adds it to the sums of the destination rows it
+// contributes to.
+func (rs *resampling) addChannels(y int, src []int32, n int) {
+	row := rs.row[:n*rs.Width]
+	for x, ws := range rs.xWeights {
+		for c := 0; c < n; c++ {
+			sum := 0.0
+			for j, weight := range ws.values {
+				sum += float64(src[(ws.start+j)*n+c]) * weight
+			}
+			row[x*n+c] = sum
+		}
+	}
+	for _, c := range rs.contributions[y] {
+		dst := rs.channels[c.row*rs.Width*n : (c.row+1)*rs.Width*n]
+		for x, v := range row {
+			dst[x] += v * c.weight
+		}
+	}
+}
+
+// channelsResult stores the gray scale values of the destination pixels,
+// whose n channels are rounded to 16 bits, into the pixels of the target.
+func (rs *resampling) channelsResult(n int, luma grayFunc) {
+	var channels [3]int32
+	for i := range rs.Pixels {
+		for c := 0; c < n; c++ {
+			v := math.Floor(rs.channels[i*n+c] + 0.5)
+			if v < 0 {
+				v = 0
+			} else if v > 0xffff {
+				v = 0xffff
+			}
+			channels[c] = int32(v)
+		}
+		rs.Pixels[i] = wideGray(channels[:n], luma)
+	}
+}
+
+// resample fills the targets of resamplings, planned for the size of img.
+// gray should hold a row of img and channels six values per pixel of it.
+func (r Resampler) resample(img image.Image, resamplings []resampling, gray []float64, channels []int32) error {
+	bounds := img.Bounds()
+	h := bounds.Dy()
+	active := false
+	for i := range resamplings {
+		rs := &resamplings[i]
+		rs.Pixels = rs.Pixels[:rs.Width*rs.Height]
+		for j := range rs.Pixels {
+			rs.Pixels[j] = 0
+		}
+		if rs.legacy != nil {
+			for j := range rs.legacy.sums {
+				rs.legacy.sums[j] = 0
+			}
+		}
+		for j := range rs.channels {
+			rs.channels[j] = 0
+		}
+		active = active || rs.active()
+	}
+	if !active {
+		return nil
+	}
+
+	luma := r.Luma.function()
+	var palette [256]float64
+	grayPalette(img, luma, &palette)
+	format := legacyFormatOf(img)
+	n := format.channels()
+	wide := channels[3*len(gray) : 3*len(gray)+n*len(gray)]
+	for y := 0; y < h; y++ {
+		used := false
+		for i := range resamplings {
+			if resamplings[i].uses(y) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			continue
+		}
+		if canceled(r.Done) {
+			return ErrCanceled
+		}
+		converted, read, widened := false, false, false
+		for i := range resamplings {
+			rs := &resamplings[i]
+			if !rs.uses(y) {
+				continue
+			}
+			if rs.legacy != nil {
+				if !read {
+					legacyRow(img, bounds.Min.Y+y, channels, format)
+					read = true
+				}
+				rs.legacy.add(y, channels[:n*len(gray)], format)
+				continue
+			}
+			if rs.channels != nil {
+				if !widened {
+					wideRow(img, bounds.Min.Y+y, wide, format)
+					widened = true
+				}
+				rs.addChannels(y, wide, n)
+				continue
+			}
+			if !converted {
+				grayRow(img, bounds.Min.Y+y, gray, luma, &palette)
+				converted = true
+			}
+			for x, ws := range rs.xWeights {
+				sum := 0.0
+				for j, weight := range ws.values {
+					sum += gray[ws.start+j] * weight
+				}
+				rs.row[x] = sum
+			}
+			for _, c := range rs.contributions[y] {
+				dst := rs.Pixels[c.row*rs.Width : (c.row+1)*rs.Width]
+				for x, v := range rs.row {
+					dst[x] += v * c.weight
+				}
+			}
+		}
+	}
+	for i := range resamplings {
+		if rs := &resamplings[i]; rs.legacy != nil {
+			rs.legacy.result(rs.Pixels, format, luma)
+		} else if rs.channels != nil {
+			rs.channelsResult(n, luma)
+		}
+	}
+	return nil
+}
+
+// Scaler resamples images as Resampler does, but keeps its weights and
+// buffers from one call to the next, so that resampling images of the same
+// size to the same size does not allocate. A Scaler should not be used by
+// several goroutines at once.
+type Scaler struct {
+	Resampler
+	resampling [1]resampling
+	gray       []float64
+	channels   []int32
+}
+
+// Resample method shrinks or enlarges img to a width x height gray scale
+// array, stored row by row into pixels which should hold width * height
+// values. The weights are computed again only when the sizes or the filter
+// change.
+func (s *Scaler) Resample(img image.Image, pixels []float64, width, height int) error {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	t := Target{Pixels: pixels, Width: width, Height: height}
+	if err := t.check(); err != nil {
+		return err
+	}
+	rs := &s.resampling[0]
+	if rs.srcWidth != w || rs.srcHeight != h || rs.Width != width || rs.Height != height || rs.filter != s.Filter {
+		rs.plan(t, w, h, s.Filter)
+	}
+	rs.Pixels = pixels
+	if cap(s.gray) < w {
+		s.gray = make([]float64, w)
+		s.channels = make([]int32, 6*w)
+	}
+	return s.Resampler.resample(img, s.resampling[:], s.gray[:w], s.channels[:6*w])
+}
+
+// grayPalette converts the palette of img to gray scale values with luma when
+// img is an *image.Paletted, so that grayRow looks its pixels up in palette.
+// Palettes hold at most 256 colors.
+func grayPalette(img image.Image, luma grayFunc, palette *[256]float64) {
+	c, ok := img.(*image.Paletted)
+	if !ok {
+		return
+	}
+	for i, pc := range c.Palette {
+		if i == len(palette) {
+			break
+		}
+		r, g, b, _ := pc.RGBA()
+		palette[i] = luma(r, g, b)
+	}
+}
+
+// grayRow converts the row y of img to gray scale values with luma.
+// Channels are passed to luma with their full 16 bits precision. palette
+// should hold the gray scale palette of img computed by grayPalette.
+func grayRow(img image.Image, y int, gray []float64, luma grayFunc, palette *[256]float64) {
+	bounds := img.Bounds()
+	switch c := img.(type) {
+	case *image.YCbCr:
+		for x := range gray {
+			yi := c.YOffset(bounds.Min.X+x, y)
+			ci := c.COffset(bounds.Min.X+x, y)
+			r, g, b, _ := color.YCbCr{Y: c.Y[yi], Cb: c.Cb[ci], Cr: c.Cr[ci]}.RGBA()
+			gray[x] = luma(r, g, b)
+		}
+	case *image.RGBA:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			p := row[x*4 : x*4+3]
+			gray[x] = luma(uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101)
+		}
+	case *image.RGBA64:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			p := row[x*8 : x*8+6]
+			gray[x] = luma(uint32(p[0])<<8|uint32(p[1]), uint32(p[2])<<8|uint32(p[3]), uint32(p[4])<<8|uint32(p[5]))
+		}
+	case *image.NRGBA:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			p := row[x*4 : x*4+4]
+			a := uint32(p[3])
+			// Premultiply as color.NRGBA.RGBA does.
+			gray[x] = luma(uint32(p[0])*0x101*a/0xff, uint32(p[1])*0x101*a/0xff, uint32(p[2])*0x101*a/0xff)
+		}
+	case *image.NRGBA64:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			p := row[x*8 : x*8+8]
+			a := uint32(p[6])<<8 | uint32(p[7])
+			r := (uint32(p[0])<<8 | uint32(p[1])) * a / 0xffff
+			g := (uint32(p[2])<<8 | uint32(p[3])) * a / 0xffff
+			b := (uint32(p[4])<<8 | uint32(p[5])) * a / 0xffff
+			gray[x] = luma(r, g, b)
+		}
+	case *image.Gray:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			v := uint32(row[x]) * 0x101
+			gray[x] = luma(v, v, v)
+		}
+	case *image.Gray16:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			v := uint32(row[x*2])<<8 | uint32(row[x*2+1])
+			gray[x] = luma(v, v, v)
+		}
+	case *image.CMYK:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			p := row[x*4 : x*4+4]
+			// Convert as color.CMYK.RGBA does.
+			w := 0xffff - uint32(p[3])*0x101
+			r := (0xffff - uint32(p[0])*0x101) * w / 0xffff
+			g := (0xffff - uint32(p[1])*0x101) * w / 0xffff
+			b := (0xffff - uint32(p[2])*0x101) * w / 0xffff
+			gray[x] = luma(r, g, b)
+		}
+	case *image.Paletted:
+		row := c.Pix[c.PixOffset(bounds.Min.X, y):]
+		for x := range gray {
+			gray[x] = palette[row[x]]
+		}
+	default:
+		for x := range gray {
+			r, g, b, _ := img.At(bounds.Min.X+x, y).RGBA()
+			gray[x] = luma(r, g, b)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestResampleBox(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 16)
+	}
+
+	for _, tt := range []struct {
+		width, height int
+		expected      []float64
+	}{
+		{2, 2, []float64{40, 72, 168, 200}},
+		{1, 1, []float64{120}},
+		{4, 4, []float64{0, 16, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224, 240}},
+		{3, 1, []float64{100, 120, 140}},
+	} {
+		pixels := make([]float64, tt.width*tt.height)
+		if err := Resample(img, pixels, tt.width, tt.height, Box); err != nil {
+			t.Fatalf("%s", err)
+		}
+		for i, v := range tt.expected {
+			if math.Abs(pixels[i]-v) > 1e-9 {
+				t.Errorf("Resample(%d, %d) is expected %v but got %v", tt.width, tt.height, tt.expected, pixels)
+				break
+			}
+		}
+	}
+}
+
+func TestResampleNearest(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 16)
+	}
+
+	for _, tt := range []struct {
+		width, height int
+		expected      []float64
+	}{
+		// Shrinking averages the nearest source pixels.
+		{2, 2, []float64{40, 72, 168, 200}},
+		{4, 1, []float64{96, 112, 128, 144}},
+		{4, 4, []float64{0, 16, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224, 240}},
+	} {
+		pixels := make([]float64, tt.width*tt.height)
+		if err := Resample(img, pixels, tt.width, tt.height, NearestNeighbor); err != nil {
+			t.Fatalf("%s", err)
+		}
+		for i, v := range tt.expected {
+			if math.Abs(pixels[i]-v) > 1e-9 {
+				t.Errorf("Resample(%d, %d) is expected %v but got %v", tt.width, tt.height, tt.expected, pixels)
+				break
+			}
+		}
+	}
+}
+
+func TestResampleUniform(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 301, 97))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:i+4], []uint8{10, 120, 230, 255})
+	}
+	expected := pixel2Gray(color.RGBA{10, 120, 230, 255}.RGBA())
+	for _, f := range []Filter{NearestNeighbor, Bilinear, Bicubic, Lanczos2, Lanczos3, Box} {
+		for _, size := range [][2]int{{8, 8}, {9, 8}, {64, 64}, {301, 97}, {400, 200}} {
+			pixels := make([]float64, size[0]*size[1])
+			Resample(img, pixels, size[0], size[1], f)
+			for i, v := range pixels {
+				if math.Abs(v-expected) > 1e-9 {
+					t.Errorf("Resample of a uniform image with filter %d to %v is expected %v but got %v at %d", f, size, expected, v, i)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestResampleImageTypes(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for i := range rgba.Pix {
+		if i%4 == 3 {
+			rgba.Pix[i] = 255
+		} else {
+			rgba.Pix[i] = uint8(i * 7)
+		}
+	}
+	ycbcr := image.NewYCbCr(rgba.Bounds(), image.YCbCrSubsampleRatio420)
+	gray := image.NewGray(rgba.Bounds())
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			c := rgba.RGBAAt(x, y)
+			gray.SetGray(x, y, color.Gray{c.G})
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			ycbcr.Y[ycbcr.YOffset(x, y)] = yy
+			ycbcr.Cb[ycbcr.COffset(x, y)] = cb
+			ycbcr.Cr[ycbcr.COffset(x, y)] = cr
+		}
+	}
+
+	// The fast paths should give the same result as the image.Image interface.
+	for _, img := range []image.Image{rgba, ycbcr, gray} {
+		fast := make([]float64, 8*8)
+		slow := make([]float64, 8*8)
+		Resample(img, fast, 8, 8, Lanczos3)
+		Resample(struct{ image.Image }{img}, slow, 8, 8, Lanczos3)
+		for i := range fast {
+			if math.Abs(fast[i]-slow[i]) > 1e-9 {
+				t.Errorf("Resample of %T is expected %v but got %v", img, slow, fast)
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkResample(b *testing.B) {
+	img := image.NewYCbCr(image.Rect(0, 0, 1024, 768), image.YCbCrSubsampleRatio420)
+	pixels := make([]float64, 64*64)
+	for i := 0; i < b.N; i++ {
+		Resample(img, pixels, 64, 64, Bilinear)
+	}
+}
+
+func TestResampleInvalid(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for _, tt := range []struct {
+		pixels        []float64
+		width, height int
+	}{
+		{make([]float64, 16), 0, 4},
+		{make([]float64, 16), 4, -1},
+		{make([]float64, 15), 4, 4},
+	} {
+		if err := Resample(img, tt.pixels, tt.width, tt.height, Bilinear); err == nil {
+			t.Errorf("Resample of %d values to %dx%d is expected to fail", len(tt.pixels), tt.width, tt.height)
+		}
+		var scaler Scaler
+		if err := scaler.Resample(img, tt.pixels, tt.width, tt.height); err == nil {
+			t.Errorf("Scaler of %d values to %dx%d is expected to fail", len(tt.pixels), tt.width, tt.height)
+		}
+	}
+}
+
+func TestResampleDone(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	pixels := make([]float64, 4*4)
+	if err := (Resampler{Filter: Bilinear}).Resample(img, pixels, 4, 4); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	done := make(chan struct{})
+	close(done)
+	if err := (Resampler{Filter: Bilinear, Done: done}).Resample(img, pixels, 4, 4); err != ErrCanceled {
+		t.Errorf("Resample with a closed channel is expected %v but got %v", ErrCanceled, err)
+	}
+}
+
+func TestScaler(t *testing.T) {
+	var scaler Scaler
+	for _, img := range append(testImages(20, 10), testImages(9, 13)...) {
+		for _, f := range []Filter{Bilinear, Lanczos3, Box} {
+			for _, size := range [][2]int{{8, 8}, {9, 8}, {5, 3}} {
+				width, height := size[0], size[1]
+				scaler.Filter = f
+				pixels := make([]float64, width*height)
+				if err := scaler.Resample(img, pixels, width, height); err != nil {
+					t.Errorf("%s", err)
+				}
+				expected := make([]float64, width*height)
+				Resampler{Filter: f}.Resample(img, expected, width, height)
+				if !reflect.DeepEqual(pixels, expected) {
+					t.Errorf("Scaler of %T with %v to %dx%d is expected %v but got %v", img, f, width, height, expected, pixels)
+				}
+			}
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	pixels := make([]float64, 64)
+	scaler.Resample(img, pixels, 8, 8)
+	if allocs := testing.AllocsPerRun(5, func() { scaler.Resample(img, pixels, 8, 8) }); allocs != 0 {
+		t.Errorf("Scaler is expected not to allocate but allocates %v times", allocs)
+	}
+}
@@ -1,5 +1,21 @@
 package transforms
 
+func forwardDCT512(input []float64) {
+	var temp [512]float64
+	for i := 0; i < 256; i++ {
+		x, y := input[i], input[512-1-i]
+		temp[i] = x + y
+		temp[i+256] = (x - y) / dct512[i]
+	}
+	forwardDCT256(temp[:256])
+	forwardDCT256(temp[256:])
+	for i := 0; i < 256-1; i++ {
+		input[i*2+0] = temp[i]
+		input[i*2+1] = temp[i+256] + temp[i+256+1]
+	}
+	input[512-2], input[512-1] = temp[256-1], temp[512-1]
+}
+
 func forwardDCT256(input []float64) {
 	var temp [256]float64
 	for i := 0; i < 128; i++ {
@@ -140,9 +156,43 @@ func forwardDCT4(input []float64) {
 
 // Static DCT Tables
 var (
-	//for i := 0; i < len(dct256); i++ {
-	//	dct256[i] = (math.Cos((float64(i)+0.5)*math.Pi/float64(256)) * 2)
+	//for i := 0; i < len(dct512); i++ {
+	//	dct512[i] = (math.Cos((float64(i)+0.5)*math.Pi/float64(512)) * 2)
 	//}
+	dct512 = [256]float64{
+		1.9999905876191524, 1.9999152891039278, 1.9997646949084251, 1.9995388107024306, 1.9992376449903573, 1.9988612091109235, 1.9984095172367278, 1.9978825863737137,
+		1.9972804363605305, 1.9966030898677858, 1.995850572397192, 1.995022912280607, 1.994120140678966, 1.99314229158111, 1.992089401802504, 1.9909615109838539,
+		1.9897586615896112, 1.988480898906376, 1.9871282710411906, 1.98570082891973, 1.9841986262843836, 1.9826217196922309, 1.9809701685129142, 1.9792440349264018,
+		1.9774433839206476, 1.9755682832891444, 1.973618803628371, 1.9715950183351347, 1.9694970036038084, 1.9673248384234605, 1.9650786045748825, 1.9627583866275091,
+		1.9603642719362346, 1.9578963506381244, 1.9553547156490199, 1.9527394626600423, 1.9500506901339882, 1.9472884993016237, 1.9444529941578725, 1.9415442814579005,
+		1.938562470713097, 1.935507674186951, 1.932380006890825, 1.9291795865796255, 1.9259065337473678, 1.9225609716226413, 1.919143026163969, 1.9156528260550656,
+		1.9120905026999928, 1.9084561902182113, 1.9047500254395318, 1.9009721478989634, 1.8971226998314605, 1.8932018261665673, 1.8892096745229605, 1.8851463952028937,
+		1.8810121411865368, 1.8768070681262161, 1.8725313343405565, 1.868185100808518, 1.8637685311633363, 1.8592817916863624, 1.8547250513008022, 1.8500984815653552,
+		1.845402256667757, 1.8406365534182212, 1.835801551242781, 1.8308974321765357, 1.8259243808567964, 1.8208825845161343, 1.8157722329753325, 1.8105935186362374,
+		1.8053466364745177, 1.8000317840323203, 1.7946491614108366, 1.7891989712627654, 1.7836814187846854, 1.7780967117093291, 1.7724450602977615, 1.7667266773314632,
+		1.7609417781043215, 1.7550905804145227, 1.7491733045563522, 1.743190173311902, 1.737141411942682, 1.7310272481811382, 1.724847912222081, 1.718603636714017,
+		1.712294656750389, 1.7059212098607273, 1.6994835360017049, 1.6929818775481043, 1.6864164792836909, 1.679787588391999, 1.6730954544470238, 1.6663403294038264,
+		1.659522467589046, 1.6526421256913268, 1.6456995627516529, 1.638695040153594, 1.6316288216134676, 1.624501173170408, 1.61731236317635, 1.6100626622859273,
+		1.6027523434462805, 1.5953816818867823, 1.5879509551086743, 1.58046044287462, 1.5729104271981718, 1.5653011923331515, 1.557633024762952, 1.5499062131897479,
+		1.5421210485236276, 1.534277823871641, 1.5263768345267625, 1.518418377956776, 1.5104027537930729, 1.5023302638193727, 1.4942012119603603, 1.4860159042702434,
+		1.4777746489212302, 1.469477756191927, 1.4611255384556552, 1.452718310168692, 1.4442563878584307, 1.4357400901114636, 1.427169737561587, 1.4185456528777314,
+		1.40986816075181, 1.401137587886497, 1.3923542629829262, 1.3835185167283157, 1.3746306817835183, 1.3656910927704962, 1.3567000862597232, 1.3476580007575123,
+		1.3385651766932722, 1.3294219564066898, 1.3202286841348412, 1.310985705999231, 1.301693369992762, 1.2923520259666328, 1.2829620256171663, 1.2735237224725686,
+		1.2640374718796181, 1.2545036309902884, 1.2449225587483002, 1.235294615875608, 1.2256201648588196, 1.2158995699355475, 1.2061331970806966, 1.1963214139926848,
+		1.1864645900795998, 1.1765630964452907, 1.1666173058753968, 1.1566275928233114, 1.1465943333960846, 1.1365179053402632, 1.1263986880276684, 1.1162370624411124,
+		1.1060334111600552, 1.0957881183462006, 1.085501569729032, 1.075174152591291, 1.064806255754396, 1.0543982695638028, 1.0439505858743088, 1.0334635980353,
+		1.022937700875941, 1.012373290690311, 1.0017707652224819, 0.9911305236515451, 0.9804529665765823, 0.9697384960015822, 0.9589875153203061, 0.9482004293011,
+		0.9373776440716559, 0.9265195671037205, 0.9156266071977546, 0.904699174467542, 0.8937376803247487, 0.8827425374634332, 0.871714159844511, 0.8606529626801652,
+		0.8495593624182176, 0.8384337767264479, 0.8272766244768691, 0.8160883257299575, 0.8048693017188371, 0.7936199748334208, 0.782340768604508, 0.771032107687838,
+		0.7596944178481022, 0.7483281259429159, 0.7369336599067446, 0.7255114487347945, 0.7140619224668601, 0.7025855121711343, 0.6910826499279783, 0.6795537688136539,
+		0.667999302884019, 0.6564196871581853, 0.6448153576021399, 0.6331867511123317, 0.621534305499223, 0.6098584594708047, 0.598159652616081, 0.5864383253885174,
+		0.5746949190894591, 0.5629298758515161, 0.5511436386219165, 0.5393366511458304, 0.527509357949663, 0.5156622043243179, 0.5037956363084338, 0.4919101006715892,
+		0.480006044897483, 0.4680839171670869, 0.4561441663417716, 0.4441872419464072, 0.4322135941524392, 0.42022367376093944, 0.408217932185634, 0.39619682143590745,
+		0.38416079409978476, 0.3721103033268932, 0.36004580281139903, 0.3479677467749277, 0.3358765899494624, 0.32377278756022376, 0.31165679530853063, 0.29952906935464324,
+		0.28739006630058916, 0.27524024317297235, 0.2630800574057665, 0.2509099668230924, 0.23873042962198268, 0.2265419043551287, 0.21434484991361774, 0.20213972550965573,
+		0.18992699065927812, 0.17770710516504937, 0.1654805290987516, 0.15324772278406323, 0.14100914677922802, 0.12876526185971482, 0.11651652900087146, 0.10426340936056663,
+		0.09200636426182929, 0.07974585517547969, 0.06748234370275528, 0.05521629155793164, 0.04294816055093922, 0.03067841256997644, 0.01840750956411992, 0.006135913525932276,
+	}
 	dct256 = [128]float64{
 		1.9999623505652022, 1.9996611635916468, 1.9990588350021863, 1.9981554555052907, 1.9969511611465895, 1.9954461332883833, 1.9936405985823316, 1.9915348289353196,
 		1.9891291414685108, 1.986423898469589, 1.983419507338199, 1.9801164205245942, 1.976515135461499, 1.9726161944891973, 1.968420184773858, 1.9639277382191105,
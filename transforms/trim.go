@@ -0,0 +1,146 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+)
+
+// TrimBorder function returns the bounds of img without its uniform borders,
+// such as letterbox bars or canvas margins. A border pixel matches border
+// when every channel differs by at most tolerance on a 0-255 scale, the
+// tolerance being clamped to [0, 255]. A nil border uses the color of the
+// top-left pixel. The bounds of img are returned when nothing but border is
+// found.
+func TrimBorder(img image.Image, border color.Color, tolerance int) image.Rectangle {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return bounds
+	}
+	if border == nil {
+		border = img.At(bounds.Min.X, bounds.Min.Y)
+	}
+	if tolerance < 0 {
+		tolerance = 0
+	} else if tolerance > 255 {
+		tolerance = 255
+	}
+	row := newBorderRow(img, border, uint32(tolerance)*0x101)
+	matches := make([]float64, bounds.Dx())
+	rowMatches := func(y int) bool {
+		row(y, matches)
+		for _, v := range matches {
+			if v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	r := bounds
+	for r.Min.Y < r.Max.Y && rowMatches(r.Min.Y) {
+		r.Min.Y++
+	}
+	if r.Min.Y == r.Max.Y {
+		return bounds
+	}
+	for rowMatches(r.Max.Y - 1) {
+		r.Max.Y--
+	}
+	// The remaining rows hold a pixel which is not border: the sides keep
+	// the leftmost and the rightmost of them.
+	minX, maxX := bounds.Dx(), 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row(y, matches)
+		for x := 0; x < minX; x++ {
+			if matches[x] == 0 {
+				minX = x
+				break
+			}
+		}
+		for x := len(matches) - 1; x >= maxX; x-- {
+			if matches[x] == 0 {
+				maxX = x + 1
+				break
+			}
+		}
+	}
+	r.Min.X, r.Max.X = bounds.Min.X+minX, bounds.Min.X+maxX
+	return r
+}
+
+// newBorderRow returns a function setting matches[x] to 1 when the pixel x
+// of the row y of img matches border within tol, to 0 otherwise. Opaque
+// images are read by grayRow, which has no alpha but fast paths for the
+// standard image types.
+func newBorderRow(img image.Image, border color.Color, tol uint32) func(y int, matches []float64) {
+	br, bg, bb, ba := border.RGBA()
+	if o, ok := img.(interface {
+		Opaque() bool
+	}); ok && o.Opaque() {
+		if !near(0xffff, ba, tol) {
+			return func(y int, matches []float64) {
+				for x := range matches {
+					matches[x] = 0
+				}
+			}
+		}
+		match := func(r, g, b uint32) float64 {
+			if near(r, br, tol) && near(g, bg, tol) && near(b, bb, tol) {
+				return 1
+			}
+			return 0
+		}
+		var palette [256]float64
+		grayPalette(img, match, &palette)
+		return func(y int, matches []float64) {
+			grayRow(img, y, matches, match, &palette)
+		}
+	}
+	minX := img.Bounds().Min.X
+	return func(y int, matches []float64) {
+		for x := range matches {
+			r, g, b, a := img.At(minX+x, y).RGBA()
+			if near(r, br, tol) && near(g, bg, tol) && near(b, bb, tol) && near(a, ba, tol) {
+				matches[x] = 1
+			} else {
+				matches[x] = 0
+			}
+		}
+	}
+}
+
+func near(a, b, tol uint32) bool {
+	if a > b {
+		return a-b <= tol
+	}
+	return b-a <= tol
+}
+
+// Crop function returns the part of img inside r, sharing its pixels.
+// The bounds of the result keep the coordinates of img.
+func Crop(img image.Image, r image.Rectangle) image.Image {
+	r = r.Intersect(img.Bounds())
+	if r == img.Bounds() {
+		return img
+	}
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	return &cropped{img, r}
+}
+
+// cropped restricts the bounds of an image which has no SubImage method.
+type cropped struct {
+	image.Image
+	bounds image.Rectangle
+}
+
+func (c *cropped) Bounds() image.Rectangle {
+	return c.bounds
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 The goimagehash Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transforms
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestTrimBorder(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	content := image.Rect(3, 5, 17, 12)
+	img := image.NewRGBA(image.Rect(0, 0, 20, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 20; x++ {
+			c := color.RGBA{uint8(x), 0, 4, 255}
+			if (image.Point{x, y}).In(content) {
+				c = color.RGBA{200, uint8(x * 10), uint8(y * 10), 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	uniform := image.NewRGBA(image.Rect(0, 0, 4, 4))
+
+	for _, tt := range []struct {
+		img       image.Image
+		border    color.Color
+		tolerance int
+		expected  image.Rectangle
+	}{
+		{img, nil, 20, content},
+		{img, black, 20, content},
+		{img, black, 4, image.Rect(3, 0, 20, 16)},
+		{img, white, 20, img.Bounds()},
+		{img, nil, 0, image.Rect(1, 0, 20, 16)},
+		{img.SubImage(image.Rect(2, 4, 18, 14)), nil, 20, content},
+		{uniform, nil, 0, uniform.Bounds()},
+		// Tolerances out of [0, 255] are clamped instead of overflowing.
+		{img, nil, 16711936, img.Bounds()},
+		{img, nil, -5, image.Rect(1, 0, 20, 16)},
+		{image.NewRGBA(image.Rect(0, 0, 0, 0)), nil, 0, image.Rect(0, 0, 0, 0)},
+	} {
+		if r := TrimBorder(tt.img, tt.border, tt.tolerance); r != tt.expected {
+			t.Errorf("TrimBorder(%v, %v, %d) is expected %v but got %v", tt.img.Bounds(), tt.border, tt.tolerance, tt.expected, r)
+		}
+	}
+}
+
+func TestTrimBorderImageTypes(t *testing.T) {
+	content := image.Rect(2, 3, 9, 7)
+	rgba := image.NewRGBA(image.Rect(0, 0, 12, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 12; x++ {
+			c := color.RGBA{10, 20, 30, 255}
+			if (image.Point{x, y}).In(content) {
+				c = color.RGBA{200, uint8(x * 20), uint8(y * 20), 255}
+			}
+			rgba.SetRGBA(x, y, c)
+		}
+	}
+	gray := image.NewGray(rgba.Bounds())
+	paletted := image.NewPaletted(rgba.Bounds(), color.Palette{color.RGBA{10, 20, 30, 255}, color.White})
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 12; x++ {
+			gray.Set(x, y, rgba.At(x, y))
+			if (image.Point{x, y}).In(content) {
+				paletted.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	// A transparent frame does not match an opaque border of the same color.
+	nrgba := image.NewNRGBA(rgba.Bounds())
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 12; x++ {
+			c := color.NRGBA{0, 0, 0, 255}
+			if x == 0 {
+				c.A = 0
+			}
+			nrgba.SetNRGBA(x, y, c)
+		}
+	}
+
+	for _, tt := range []struct {
+		img      image.Image
+		border   color.Color
+		expected image.Rectangle
+	}{
+		{rgba, nil, content},
+		{gray, nil, content},
+		{paletted, nil, content},
+		{nrgba, color.Black, image.Rect(0, 0, 1, 10)},
+	} {
+		// The fast paths should give the same result as the image.Image interface.
+		for _, img := range []image.Image{tt.img, struct{ image.Image }{tt.img}} {
+			if r := TrimBorder(img, tt.border, 2); r != tt.expected {
+				t.Errorf("TrimBorder of %T is expected %v but got %v", img, tt.expected, r)
+			}
+		}
+	}
+}
+
+func TestCrop(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	img.SetGray(3, 4, color.Gray{42})
+	r := image.Rect(2, 3, 6, 7)
+
+	for _, src := range []image.Image{img, struct{ image.Image }{img}} {
+		c := Crop(src, r)
+		if c.Bounds() != r {
+			t.Errorf("Crop(%T) is expected bounds %v but got %v", src, r, c.Bounds())
+		}
+		if g := color.GrayModel.Convert(c.At(3, 4)).(color.Gray); g.Y != 42 {
+			t.Errorf("Crop(%T) is expected to keep the pixels of the image but got %v", src, g)
+		}
+	}
+	if c := Crop(img, image.Rect(-1, -1, 9, 9)); c != image.Image(img) {
+		t.Errorf("Crop larger than the image is expected to return the image")
+	}
+}